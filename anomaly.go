@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"mem-monitor/collect"
+)
+
+// spikeDetector logs when VRAM usage jumps well above its recent rolling
+// mean, so transient spikes are recorded even when nobody is watching
+type spikeDetector struct {
+	sigma   float64 // how many standard deviations above the mean counts as a spike
+	window  int
+	samples []float64
+	logger  *log.Logger // optional; without one spikes are only returned
+}
+
+func newSpikeDetector(logger *log.Logger, sigma float64) *spikeDetector {
+	return &spikeDetector{
+		sigma:  sigma,
+		window: 60,
+		logger: logger,
+	}
+}
+
+// observe checks the selected card's VRAM usage against the window, then
+// adds it to the window. A spike is logged if there is a logger, and
+// returned either way; "" when there was none.
+func (d *spikeDetector) observe(gpu collect.GPUInfo, procs []collect.ProcessGPUInfo) (spike string) {
+	val := float64(gpu.VRAMUsed)
+
+	// Need some history before the statistics mean anything
+	if len(d.samples) >= 10 {
+		mean, std := meanStddev(d.samples)
+		// Ignore sub-MiB wobble on an otherwise flat line
+		if val > mean+d.sigma*std && val-mean >= 1<<20 {
+			msg := "VRAM spike"
+			if gpu.Card != "" {
+				msg += " on " + gpu.Card
+			}
+			msg += ": " + formatBytes(gpu.VRAMUsed) + " (mean " + formatBytes(uint64(mean)) + ", stddev " + formatBytes(uint64(std)) + ")"
+			if top, ok := topVRAMProcess(procs); ok {
+				msg += fmt.Sprintf("; top process %d %s using %s", top.PID, top.Name, formatBytes(top.VRAM))
+			}
+			if d.logger != nil {
+				d.logger.Print(msg)
+			}
+			spike = msg
+		}
+	}
+
+	d.samples = append(d.samples, val)
+	if len(d.samples) > d.window {
+		d.samples = d.samples[1:]
+	}
+	return spike
+}
+
+// reset forgets the window, e.g. when another card is selected, whose VRAM
+// says nothing about the previous card's mean
+func (d *spikeDetector) reset() {
+	d.samples = nil
+}
+
+func meanStddev(vals []float64) (mean, std float64) {
+	for _, v := range vals {
+		mean += v
+	}
+	mean /= float64(len(vals))
+	for _, v := range vals {
+		std += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(std / float64(len(vals)))
+}
+
+func topVRAMProcess(procs []collect.ProcessGPUInfo) (collect.ProcessGPUInfo, bool) {
+	var top collect.ProcessGPUInfo
+	found := false
+	for _, p := range procs {
+		if !found || p.VRAM > top.VRAM {
+			top, found = p, true
+		}
+	}
+	return top, found
+}
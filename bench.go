@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+
+	"mem-monitor/collect"
+)
+
+// benchCollector is one stage of the collection path --bench times
+type benchCollector struct {
+	name string
+	run  func() error
+}
+
+var benchCollectors = []benchCollector{
+	{"GetAllGPUStats", func() error { _, err := collect.GetAllGPUStats(); return err }},
+	{"GetProcessBreakdown", func() error { _, _, err := collect.GetProcessBreakdown(); return err }},
+}
+
+// runBench runs each collector n times after a warm-up run, which fills
+// caches such as the device list the way a running monitor has them, and
+// prints timing percentiles per collector. Cancelling ctx cuts the runs
+// short, what was timed so far is still reported.
+func runBench(ctx context.Context, out io.Writer, n int) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COLLECTOR\tRUNS\tMIN\tP50\tP90\tP99\tMAX")
+	for _, c := range benchCollectors {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := c.run(); err != nil {
+			// Machines without a GPU can still time the process scan
+			fmt.Fprintf(w, "%s\t0\tfailed: %v\n", c.name, err)
+			continue
+		}
+		var times []time.Duration
+		for len(times) < n && ctx.Err() == nil {
+			start := time.Now()
+			if err := c.run(); err != nil {
+				return fmt.Errorf("%s: %w", c.name, err)
+			}
+			times = append(times, time.Since(start))
+			collect.TakeErrors() // nothing would ever read them
+		}
+		if len(times) == 0 {
+			break
+		}
+		slices.Sort(times)
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", c.name, len(times), benchDuration(times[0]),
+			benchDuration(percentile(times, 50)), benchDuration(percentile(times, 90)),
+			benchDuration(percentile(times, 99)), benchDuration(times[len(times)-1]))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	// The process scan mostly scales with how many there are
+	pids, err := process.Pids()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%d processes\n", len(pids))
+	return err
+}
+
+// percentile is the nearest-rank percentile p of sorted times
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+func benchDuration(d time.Duration) string {
+	return d.Round(time.Microsecond).String()
+}
@@ -0,0 +1,173 @@
+package collect
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CgroupDir restricts the process breakdown to one cgroup, e.g. a
+// container or systemd unit, when set. mem-monitor sets it from --cgroup.
+var CgroupDir string
+
+// cgroupPIDs lists the processes in dir and every cgroup below it. Only
+// leaf cgroups hold processes on cgroup v2, so a pod or slice is empty on
+// its own.
+func cgroupPIDs(dir string) (map[int32]bool, error) {
+	pids := map[int32]bool{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil // a child cgroup removed mid-walk
+		}
+		if d.IsDir() || d.Name() != "cgroup.procs" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		for _, line := range strings.Fields(string(data)) {
+			if pid, err := strconv.ParseInt(line, 10, 32); err == nil {
+				pids[int32(pid)] = true
+			}
+		}
+		return nil
+	})
+	return pids, err
+}
+
+// cgroupRoot is where the cgroup hierarchies are mounted
+const cgroupRoot = "/sys/fs/cgroup"
+
+// memoryCgroup returns the directory of the memory cgroup pid is in and
+// the name of its limit file, which tells v1 and v2 apart
+func memoryCgroup(pid int32) (dir, limitFile string) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(int(pid)), "cgroup"))
+	if err != nil {
+		return "", ""
+	}
+
+	// "4:memory:/docker/abc" on cgroup v1, "0::/system.slice/foo.service"
+	// on v2; a v1 memory controller takes precedence in hybrid setups
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		switch {
+		case parts[1] == "memory":
+			dir, limitFile = filepath.Join(cgroupRoot, "memory", parts[2]), "memory.limit_in_bytes"
+		case parts[0] == "0" && parts[1] == "" && limitFile == "":
+			dir, limitFile = filepath.Join(cgroupRoot, parts[2]), "memory.max"
+		}
+	}
+	return dir, limitFile
+}
+
+// cgroupMemoryLimit is the effective memory limit of the cgroup pid is in,
+// the tightest one up the hierarchy since any of them can OOM the process,
+// or 0 when unlimited. Limits are cached by cgroup in limits.
+func cgroupMemoryLimit(pid int32, limits map[string]uint64) uint64 {
+	dir, file := memoryCgroup(pid)
+	if file == "" {
+		return 0
+	}
+	if limit, ok := limits[dir]; ok {
+		return limit
+	}
+	limit := effectiveLimit(dir, file)
+	limits[dir] = limit
+	return limit
+}
+
+// effectiveLimit is the tightest limit in file of dir and its parents, 0
+// for none
+func effectiveLimit(dir, file string) uint64 {
+	var limit uint64
+	for d := dir; strings.HasPrefix(d, cgroupRoot); d = filepath.Dir(d) {
+		// "max" on v2; v1 reports a huge page-aligned number instead
+		if v, err := parseUint64File(filepath.Join(d, file)); err == nil && v < 1<<62 && (limit == 0 || v < limit) {
+			limit = v
+		}
+		if d == cgroupRoot {
+			break
+		}
+	}
+	return limit
+}
+
+// CgroupMemory is the memory accounting of one cgroup, which inside a
+// container describes what the host-wide meminfo numbers can't
+type CgroupMemory struct {
+	Path   string `json:"path"`
+	Usage  uint64 `json:"usage"`
+	Limit  uint64 `json:"limit"`  // tightest up the hierarchy, 0 when unlimited
+	Anon   uint64 `json:"anon"`   // process memory not backed by files
+	File   uint64 `json:"file"`   // page cache
+	Kernel uint64 `json:"kernel"` // slab, stacks, page tables
+	Shmem  uint64 `json:"shmem"`
+}
+
+// MonitoredCgroup picks whose memory.stat to show: CgroupDir, or
+// the one this tool runs in if that has a memory limit, i.e. when running
+// in a container. Empty when neither applies.
+func MonitoredCgroup() (dir, limitFile string) {
+	if CgroupDir != "" {
+		if _, err := os.Stat(filepath.Join(CgroupDir, "memory.max")); err == nil {
+			return CgroupDir, "memory.max"
+		}
+		return CgroupDir, "memory.limit_in_bytes"
+	}
+	dir, file := memoryCgroup(int32(os.Getpid()))
+	if file == "" || effectiveLimit(dir, file) == 0 {
+		return "", ""
+	}
+	return dir, file
+}
+
+// readCgroupMemory reads the usage and memory.stat breakdown of a cgroup
+// v2 (limitFile memory.max) or v1 (memory.limit_in_bytes) directory
+func readCgroupMemory(dir, limitFile string) (*CgroupMemory, error) {
+	usageFile, anon, file := "memory.current", "anon", "file"
+	if limitFile != "memory.max" {
+		usageFile, anon, file = "memory.usage_in_bytes", "rss", "cache"
+	}
+	usage, err := parseUint64File(filepath.Join(dir, usageFile))
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "memory.stat"))
+	if err != nil {
+		return nil, err
+	}
+	stat := map[string]uint64{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if key, val, ok := strings.Cut(line, " "); ok {
+			stat[key], _ = strconv.ParseUint(val, 10, 64)
+		}
+	}
+
+	cm := &CgroupMemory{
+		Path:   dir,
+		Usage:  usage,
+		Limit:  effectiveLimit(dir, limitFile),
+		Anon:   stat[anon],
+		File:   stat[file],
+		Kernel: stat["kernel"],
+		Shmem:  stat["shmem"],
+	}
+	switch {
+	case limitFile != "memory.max":
+		// v1 keeps kernel memory out of memory.stat
+		cm.Kernel, _ = parseUint64File(filepath.Join(dir, "memory.kmem.usage_in_bytes"))
+	case cm.Kernel == 0:
+		// "kernel" is only there since Linux 5.18
+		cm.Kernel = stat["slab"] + stat["kernel_stack"] + stat["pagetables"]
+	}
+	return cm, nil
+}
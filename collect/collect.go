@@ -0,0 +1,181 @@
+// Package collect reads host memory, GPU memory and per-process GPU usage
+// from /proc and sysfs. It is mem-monitor's collector, usable on its own.
+package collect
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// Snapshot is one sample of system RAM, GPU and per-process memory
+type Snapshot struct {
+	Timestamp time.Time `json:"timestamp"`
+	TotalRAM  uint64    `json:"total_ram"`
+	UsedRAM   uint64    `json:"used_ram"`
+
+	// MemAvailable: free memory plus what the kernel estimates it can
+	// reclaim without swapping
+	AvailableRAM uint64 `json:"available_ram"`
+	FreeRAM      uint64 `json:"free_ram"` // MemFree: not even used as cache
+
+	// Memory promised to processes (Committed_AS) and the limit the kernel
+	// would enforce with strict overcommit (CommitLimit)
+	CommittedAS uint64 `json:"committed_as"`
+	CommitLimit uint64 `json:"commit_limit"`
+
+	SwapTotal uint64       `json:"swap_total"`
+	SwapUsed  uint64       `json:"swap_used"`
+	Zram      []ZramDevice `json:"zram,omitempty"`
+
+	// Kernel slab allocations, which don't show up per process
+	SlabReclaimable uint64 `json:"slab_reclaimable"`
+	SlabUnreclaim   uint64 `json:"slab_unreclaimable"`
+
+	// Memory of CgroupDir, or of the container this runs in, when either
+	// applies
+	Cgroup *CgroupMemory `json:"cgroup,omitempty"`
+
+	GPUs      []GPUInfo        `json:"gpus"`
+	Processes []ProcessGPUInfo `json:"processes"`
+	ScanTime  time.Duration    `json:"scan_time_ns"` // time spent on the GPU and process scans
+
+	// GPU fds exist but their fdinfo has no memory keys we know, so the
+	// per-process VRAM/GTT columns are unknown rather than zero
+	NoFdinfoMemory bool `json:"no_fdinfo_memory"`
+
+	// Non-fatal errors of this scan, e.g. reads that fell back to the
+	// previous value or processes that exited mid-scan
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Collect gathers a Snapshot. It is the single collection path shared by
+// mem-monitor's TUI and output modes and by programs embedding the
+// collector; CollectSummary is the cheap variant without processes.
+func Collect() (Snapshot, error) {
+	snap := Snapshot{Timestamp: time.Now()}
+	if err := collectMemory(&snap); err != nil {
+		return snap, err
+	}
+
+	var err error
+	if dir, limitFile := MonitoredCgroup(); dir != "" {
+		if snap.Cgroup, err = readCgroupMemory(dir, limitFile); err != nil {
+			noteError("cgroup memory: %v", err)
+		}
+	}
+
+	start := time.Now()
+	if snap.GPUs, err = GetAllGPUStats(); err != nil {
+		noteError("GPU stats: %v", err)
+	}
+	if snap.Processes, snap.NoFdinfoMemory, err = GetProcessBreakdown(); err != nil {
+		noteError("process scan: %v", err)
+	}
+	sanitizeProcessUnits(snap.Processes, snap.GPUs)
+	snap.ScanTime = time.Since(start)
+	snap.Errors = TakeErrors()
+
+	return snap, nil
+}
+
+// CollectSummary gathers only the host memory and card totals of a
+// Snapshot, without the per-process scan, for callers that run often and
+// show no processes
+func CollectSummary() (Snapshot, error) {
+	snap := Snapshot{Timestamp: time.Now()}
+	if err := collectMemory(&snap); err != nil {
+		return snap, err
+	}
+	var err error
+	start := time.Now()
+	if snap.GPUs, err = GetAllGPUStats(); err != nil {
+		noteError("GPU stats: %v", err)
+	}
+	snap.ScanTime = time.Since(start)
+	snap.Errors = TakeErrors()
+	return snap, nil
+}
+
+// collectMemory fills in the host RAM and swap fields of snap
+func collectMemory(snap *Snapshot) error {
+	v, err := mem.VirtualMemory()
+	if err != nil {
+		// Unusual kernels can trip gopsutil up on fields we don't need
+		fallback, ferr := readMeminfo(procRoot)
+		if ferr != nil {
+			return fmt.Errorf("%w (reading meminfo directly: %v)", err, ferr)
+		}
+		v = fallback
+		noteError("gopsutil: %v, read meminfo directly", err)
+	}
+	snap.TotalRAM = v.Total
+	snap.UsedRAM = v.Used
+	snap.AvailableRAM = v.Available
+	snap.FreeRAM = v.Free
+	snap.CommittedAS = v.CommittedAS
+	snap.CommitLimit = v.CommitLimit
+	snap.SlabReclaimable = v.Sreclaimable
+	snap.SlabUnreclaim = v.Sunreclaim
+	snap.SwapTotal = v.SwapTotal
+	snap.SwapUsed = v.SwapTotal - min(v.SwapFree, v.SwapTotal)
+	snap.Zram = readZram()
+	return nil
+}
+
+// procRoot is where procfs is read from; a fixture directory can stand in
+var procRoot = "/proc"
+
+// readMeminfo parses <root>/meminfo into the fields Collect uses, with
+// Used computed the way gopsutil does so the numbers don't jump when it
+// kicks in
+func readMeminfo(root string) (*mem.VirtualMemoryStat, error) {
+	f, err := os.Open(filepath.Join(root, "meminfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fields := map[string]uint64{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// "MemTotal:       16318440 kB"
+		key, rest, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		value := strings.Fields(strings.Replace(rest, "kB", "KiB", 1))
+		if len(value) > 0 {
+			fields[key] = parseMemValue(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if fields["MemTotal"] == 0 {
+		return nil, fmt.Errorf("no MemTotal in %s", f.Name())
+	}
+
+	v := &mem.VirtualMemoryStat{
+		Total:        fields["MemTotal"],
+		Available:    fields["MemAvailable"],
+		Free:         fields["MemFree"],
+		Buffers:      fields["Buffers"],
+		Cached:       fields["Cached"] + fields["SReclaimable"],
+		Sreclaimable: fields["SReclaimable"],
+		Sunreclaim:   fields["SUnreclaim"],
+		CommittedAS:  fields["Committed_AS"],
+		CommitLimit:  fields["CommitLimit"],
+		SwapTotal:    fields["SwapTotal"],
+		SwapFree:     fields["SwapFree"],
+	}
+	if unused := v.Free + v.Buffers + v.Cached; unused < v.Total {
+		v.Used = v.Total - unused
+	}
+	return v, nil
+}
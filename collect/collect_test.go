@@ -0,0 +1,97 @@
+package collect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadMeminfo(t *testing.T) {
+	v, err := readMeminfo(filepath.Join("testdata", "proc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const kib = 1024
+	tests := []struct {
+		name      string
+		got, want uint64
+	}{
+		{"Total", v.Total, 16318440 * kib},
+		{"Available", v.Available, 9876540 * kib},
+		{"Free", v.Free, 1234560 * kib},
+		{"Buffers", v.Buffers, 345670 * kib},
+		// Reclaimable slab counts as cache, as in gopsutil
+		{"Cached", v.Cached, (6543210 + 456780) * kib},
+		{"Sreclaimable", v.Sreclaimable, 456780 * kib},
+		{"Sunreclaim", v.Sunreclaim, 123450 * kib},
+		{"CommittedAS", v.CommittedAS, 12345678 * kib},
+		{"CommitLimit", v.CommitLimit, 16547824 * kib},
+		{"SwapTotal", v.SwapTotal, 8388604 * kib},
+		{"SwapFree", v.SwapFree, 8000000 * kib},
+		// Total - Free - Buffers - Cached - SReclaimable
+		{"Used", v.Used, (16318440 - 1234560 - 345670 - 6543210 - 456780) * kib},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestReadMeminfoMissingFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		meminfo  string
+		wantErr  bool
+		wantUsed uint64
+	}{
+		{
+			name:    "no MemTotal",
+			meminfo: "MemFree: 1000 kB\nMemAvailable: 2000 kB\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			wantErr: true,
+		},
+		{
+			// Older kernels have no SReclaimable; Used leaves it out
+			name:     "no SReclaimable",
+			meminfo:  "MemTotal: 8000 kB\nMemFree: 1000 kB\nBuffers: 500 kB\nCached: 2500 kB\n",
+			wantUsed: (8000 - 1000 - 500 - 2500) * 1024,
+		},
+		{
+			// Free, buffers and cache can't add up to more than there is
+			name:     "unused above total",
+			meminfo:  "MemTotal: 1000 kB\nMemFree: 900 kB\nCached: 200 kB\n",
+			wantUsed: 0,
+		},
+	}
+	for _, tt := range tests {
+		root := t.TempDir()
+		if err := os.WriteFile(filepath.Join(root, "meminfo"), []byte(tt.meminfo), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		v, err := readMeminfo(root)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: readMeminfo succeeded, want an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if v.Used != tt.wantUsed {
+			t.Errorf("%s: Used = %d, want %d", tt.name, v.Used, tt.wantUsed)
+		}
+	}
+}
+
+func TestReadMeminfoNoFile(t *testing.T) {
+	if _, err := readMeminfo(t.TempDir()); err == nil {
+		t.Error("readMeminfo of a directory without meminfo succeeded, want an error")
+	}
+}
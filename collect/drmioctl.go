@@ -0,0 +1,72 @@
+package collect
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// Fallback for cards whose mem_info_* sysfs files are missing or hidden:
+// the same numbers through the AMDGPU_INFO ioctl on the card's render node.
+// The structs mirror include/uapi/drm/amdgpu_drm.h.
+
+const (
+	drmIoctlAMDGPUInfo = 0x40206445 // DRM_IOW(DRM_COMMAND_BASE + DRM_AMDGPU_INFO, struct drm_amdgpu_info)
+	amdgpuInfoMemory   = 0x19       // AMDGPU_INFO_MEMORY
+)
+
+type drmAMDGPUInfo struct {
+	returnPointer unsafe.Pointer // __u64; a typed pointer keeps the target alive
+	returnSize    uint32
+	query         uint32
+	_             [16]byte // per-query arguments, unused by AMDGPU_INFO_MEMORY
+}
+
+type amdgpuHeapInfo struct {
+	totalHeapSize  uint64
+	usableHeapSize uint64
+	heapUsage      uint64
+	maxAllocation  uint64
+}
+
+type amdgpuMemoryInfo struct {
+	vram              amdgpuHeapInfo
+	cpuAccessibleVRAM amdgpuHeapInfo
+	gtt               amdgpuHeapInfo
+}
+
+// RenderNode returns the /dev/dri render node of a card, or ""
+func RenderNode(deviceDir string) string {
+	nodes, _ := filepath.Glob(filepath.Join(deviceDir, "drm", "renderD*"))
+	if len(nodes) == 0 {
+		return ""
+	}
+	return filepath.Join("/dev/dri", filepath.Base(nodes[0]))
+}
+
+// queryAMDGPUMemory asks the driver for VRAM and GTT usage. Render nodes
+// are usually world-accessible, unlike sysfs inside some sandboxes.
+func queryAMDGPUMemory(node string) (amdgpuMemoryInfo, error) {
+	var info amdgpuMemoryInfo
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		return info, fmt.Errorf("AMDGPU_INFO needs a 64-bit build")
+	}
+
+	f, err := os.OpenFile(node, os.O_RDWR, 0)
+	if err != nil {
+		return info, err
+	}
+	defer f.Close()
+
+	req := drmAMDGPUInfo{
+		returnPointer: unsafe.Pointer(&info),
+		returnSize:    uint32(unsafe.Sizeof(info)),
+		query:         amdgpuInfoMemory,
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), drmIoctlAMDGPUInfo, uintptr(unsafe.Pointer(&req))); errno != 0 {
+		return info, fmt.Errorf("AMDGPU_INFO on %s: %w", node, errno)
+	}
+	return info, nil
+}
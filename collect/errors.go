@@ -0,0 +1,32 @@
+package collect
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Non-fatal errors noted during a scan, until Collect hands them to the
+// snapshot. Scans run off the UI goroutine, hence the lock.
+var (
+	pendingErrorsMu sync.Mutex
+	pendingErrors   []string
+)
+
+// noteError records something that went wrong but didn't stop the scan,
+// e.g. a sysfs read that fell back to the previous value
+func noteError(format string, args ...any) {
+	pendingErrorsMu.Lock()
+	defer pendingErrorsMu.Unlock()
+	pendingErrors = append(pendingErrors, fmt.Sprintf(format, args...))
+}
+
+// TakeErrors returns and clears the errors noted since the last call.
+// Collect does this itself; callers of the individual scans use it to
+// drain what those noted.
+func TakeErrors() []string {
+	pendingErrorsMu.Lock()
+	defer pendingErrorsMu.Unlock()
+	errs := pendingErrors
+	pendingErrors = nil
+	return errs
+}
@@ -0,0 +1,1066 @@
+package collect
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+type GPUInfo struct {
+	Card      string `json:"card"` // DRM card name, e.g. "card1"
+	DeviceDir string `json:"device_dir"`
+	PCI       string `json:"pci"`   // PCI address, stable across boots unlike the card index
+	Model     string `json:"model"` // marketing name if known, else the PCI vendor:device id
+	IsVF      bool   `json:"is_vf"` // SR-IOV virtual function
+	VRAMTotal uint64 `json:"vram_total"`
+	VRAMUsed  uint64 `json:"vram_used"`
+	GTTTotal  uint64 `json:"gtt_total"`
+	GTTUsed   uint64 `json:"gtt_used"`
+
+	// Current PCIe link state, empty when not reported
+	PCIeLinkSpeed string `json:"pcie_link_speed"` // e.g. "16.0 GT/s PCIe"
+	PCIeLinkWidth string `json:"pcie_link_width"` // lanes, e.g. "16"
+
+	// Every numeric mem_info_* pool, e.g. visible VRAM, preemptible GTT
+	Domains []MemDomain `json:"domains"`
+
+	// Fan readings from hwmon; HasFan is false on passively cooled cards
+	HasFan     bool    `json:"has_fan"`
+	FanRPM     uint64  `json:"fan_rpm"`
+	FanPercent float64 `json:"fan_percent"`
+
+	// DPM performance level, e.g. "auto", "low", "high", "manual" or a
+	// profile_* one, and the power state it was asked for such as
+	// "balanced"; empty when not reported
+	PerfLevel  string `json:"perf_level"`
+	PowerState string `json:"power_state"`
+
+	// How busy the memory controller is, from mem_busy_percent. amdgpu has
+	// no byte counters to derive a bandwidth from, so this utilization is
+	// the closest it gets. HasMemBusy is false where it isn't exposed.
+	HasMemBusy     bool    `json:"has_mem_busy"`
+	MemBusyPercent float64 `json:"mem_busy_percent"`
+
+	// Graphics engine load from gpu_busy_percent, and the edge temperature
+	// with its critical limit from hwmon, in °C. The Has* flags are false
+	// where the files don't exist; TempCrit is 0 when there's no limit.
+	HasBusy     bool    `json:"has_busy"`
+	BusyPercent float64 `json:"busy_percent"`
+	HasTemp     bool    `json:"has_temp"`
+	Temp        float64 `json:"temp"`
+	TempCrit    float64 `json:"temp_crit"`
+}
+
+// Fan describes the fan state, e.g. "1200 RPM (45%)"
+func (g GPUInfo) Fan() string {
+	if !g.HasFan {
+		return "passive"
+	}
+	return fmt.Sprintf("%d RPM (%.0f%%)", g.FanRPM, g.FanPercent)
+}
+
+// Power describes the DPM state, e.g. "auto (balanced)"
+func (g GPUInfo) Power() string {
+	switch {
+	case g.PerfLevel == "":
+		return "n/a"
+	case g.PowerState == "":
+		return g.PerfLevel
+	}
+	return fmt.Sprintf("%s (%s)", g.PerfLevel, g.PowerState)
+}
+
+// MemBandwidth describes memory controller load, e.g. "35% busy"
+func (g GPUInfo) MemBandwidth() string {
+	if !g.HasMemBusy {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.0f%% busy", g.MemBusyPercent)
+}
+
+// MemDomain is one amdgpu memory pool from the mem_info_<name>_{used,total}
+// sysfs files. Pools that only report usage have a zero Total.
+type MemDomain struct {
+	Name  string `json:"name"`
+	Used  uint64 `json:"used"`
+	Total uint64 `json:"total"`
+}
+
+// PCIeLink describes the link as generation and width, e.g. "4.0 x16"
+func (g GPUInfo) PCIeLink() string {
+	if g.PCIeLinkSpeed == "" || g.PCIeLinkWidth == "" {
+		return "n/a"
+	}
+	gens := map[string]string{
+		"2.5": "1.0", "5.0": "2.0", "8.0": "3.0",
+		"16.0": "4.0", "32.0": "5.0", "64.0": "6.0",
+	}
+	rate, _, _ := strings.Cut(g.PCIeLinkSpeed, " ")
+	gen, ok := gens[rate]
+	if !ok {
+		return g.PCIeLinkSpeed + " x" + g.PCIeLinkWidth
+	}
+	return gen + " x" + g.PCIeLinkWidth
+}
+
+func GetGPUStats() (GPUInfo, error) {
+	gpus, err := GetAllGPUStats()
+	if err != nil {
+		return GPUInfo{}, err
+	}
+	return gpus[0], nil
+}
+
+// GetAllGPUStats reads the memory stats of every detected amdgpu card
+func GetAllGPUStats() ([]GPUInfo, error) {
+	devices := AMDDevices()
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("no AMD GPU found in sysfs")
+	}
+
+	gpus := make([]GPUInfo, 0, len(devices))
+	for _, deviceDir := range devices {
+		gpus = append(gpus, readGPUInfo(deviceDir))
+	}
+	return gpus, nil
+}
+
+// GetGPUStatsByPCI reads the stats of the card at a PCI address such as
+// "0000:03:00.0". The domain may be left out.
+func GetGPUStatsByPCI(addr string) (GPUInfo, error) {
+	gpus, err := GetAllGPUStats()
+	if err != nil {
+		return GPUInfo{}, err
+	}
+	if i := FindGPUByPCI(gpus, addr); i >= 0 {
+		return gpus[i], nil
+	}
+	return GPUInfo{}, fmt.Errorf("no AMD GPU at PCI address %s", addr)
+}
+
+// FindGPUByPCI returns the index of the card at addr, or -1
+func FindGPUByPCI(gpus []GPUInfo, addr string) int {
+	for i, g := range gpus {
+		if g.PCI == addr || strings.HasSuffix(g.PCI, ":"+addr) {
+			return i
+		}
+	}
+	return -1
+}
+
+func readGPUInfo(deviceDir string) GPUInfo {
+	info := GPUInfo{
+		Card:      filepath.Base(filepath.Dir(deviceDir)),
+		DeviceDir: deviceDir,
+		PCI:       PCIAddress(deviceDir),
+		Model:     GPUModel(deviceDir),
+		IsVF:      isVirtualFunction(deviceDir),
+	}
+
+	info.VRAMUsed = readStableUint64(filepath.Join(deviceDir, "mem_info_vram_used"))
+	info.VRAMTotal = readStableUint64(filepath.Join(deviceDir, "mem_info_vram_total"))
+	info.GTTUsed = readStableUint64(filepath.Join(deviceDir, "mem_info_gtt_used"))
+	info.GTTTotal = readStableUint64(filepath.Join(deviceDir, "mem_info_gtt_total"))
+	info.VRAMUsed, info.VRAMTotal = scaleMiBReadings(info.VRAMUsed, info.VRAMTotal)
+	info.GTTUsed, info.GTTTotal = scaleMiBReadings(info.GTTUsed, info.GTTTotal)
+	if info.VRAMTotal == 0 {
+		if mi, err := queryAMDGPUMemory(RenderNode(deviceDir)); err == nil {
+			info.VRAMUsed, info.VRAMTotal = mi.vram.heapUsage, mi.vram.totalHeapSize
+			info.GTTUsed, info.GTTTotal = mi.gtt.heapUsage, mi.gtt.totalHeapSize
+		}
+	}
+	info.Domains = readMemDomains(deviceDir)
+	info.PCIeLinkSpeed = readString(filepath.Join(deviceDir, "current_link_speed"))
+	info.PCIeLinkWidth = readString(filepath.Join(deviceDir, "current_link_width"))
+	info.PerfLevel = readString(filepath.Join(deviceDir, "power_dpm_force_performance_level"))
+	info.PowerState = readString(filepath.Join(deviceDir, "power_dpm_state"))
+	if busy, err := parseUint64File(filepath.Join(deviceDir, "mem_busy_percent")); err == nil {
+		info.HasMemBusy = true
+		info.MemBusyPercent = float64(busy)
+	}
+	if busy, err := parseUint64File(filepath.Join(deviceDir, "gpu_busy_percent")); err == nil {
+		info.HasBusy = true
+		info.BusyPercent = float64(busy)
+	}
+
+	if hwmon := HwmonDir(deviceDir); hwmon != "" {
+		// temp1 is the edge sensor, in millidegrees
+		if temp, err := parseUint64File(filepath.Join(hwmon, "temp1_input")); err == nil {
+			info.HasTemp = true
+			info.Temp = float64(temp) / 1000
+			crit, _ := parseUint64File(filepath.Join(hwmon, "temp1_crit"))
+			info.TempCrit = float64(crit) / 1000
+		}
+
+		rpm := readString(filepath.Join(hwmon, "fan1_input"))
+		pwm := readString(filepath.Join(hwmon, "pwm1"))
+		if rpm != "" || pwm != "" {
+			info.HasFan = true
+			info.FanRPM, _ = strconv.ParseUint(rpm, 10, 64)
+			duty, _ := strconv.ParseUint(pwm, 10, 64)
+			info.FanPercent = float64(duty) / 255 * 100
+		}
+	}
+
+	return info
+}
+
+// scaleMiBReadings fixes up mem_info_* pairs from the few amdgpu versions
+// that report MiB rather than bytes. No GPU memory pool is under a MiB, so
+// a total that small can only be a MiB count.
+func scaleMiBReadings(used, total uint64) (uint64, uint64) {
+	if total == 0 || total >= 1<<20 {
+		return used, total
+	}
+	return used << 20, total << 20
+}
+
+// findAMDDevices returns the sysfs device directories of real amdgpu cards.
+// The first glob match isn't necessarily one, e.g. card0 may be a virtual
+// display device while card1 is the actual GPU. On SR-IOV hosts physical
+// functions are listed before their virtual functions, so the default card
+// is the one with the real memory stats.
+func findAMDDevices() []string {
+	var devices, vfs []string
+	for _, deviceDir := range CardDevices() {
+		if ReadUevent(deviceDir)["DRIVER"] != "amdgpu" {
+			continue
+		}
+		if readUint64(filepath.Join(deviceDir, "mem_info_vram_total")) == 0 && !hasIoctlVRAM(deviceDir) {
+			continue
+		}
+		if isVirtualFunction(deviceDir) {
+			vfs = append(vfs, deviceDir)
+		} else {
+			devices = append(devices, deviceDir)
+		}
+	}
+	return append(devices, vfs...)
+}
+
+// CardDevices returns the sysfs device directory of every DRM card,
+// whatever its driver
+func CardDevices() []string {
+	// card[0-9]* alone would also match connectors such as card1-DP-1
+	cards, _ := filepath.Glob("/sys/class/drm/card[0-9]*/device")
+	return slices.DeleteFunc(cards, func(deviceDir string) bool {
+		return strings.Contains(filepath.Base(filepath.Dir(deviceDir)), "-")
+	})
+}
+
+// deviceRescanInterval is how often AMDDevices looks for newly attached
+// cards, e.g. an eGPU; probing every card each tick would be wasteful
+const deviceRescanInterval = 10 * time.Second
+
+var (
+	devicesMu     sync.Mutex
+	devices       []string
+	devicesListed time.Time
+)
+
+// AMDDevices is findAMDDevices cached for deviceRescanInterval. A listed
+// card that has disappeared from sysfs triggers a rescan right away, so
+// removed GPUs are dropped on the next tick.
+func AMDDevices() []string {
+	devicesMu.Lock()
+	defer devicesMu.Unlock()
+
+	stale := time.Since(devicesListed) >= deviceRescanInterval
+	for _, deviceDir := range devices {
+		if _, err := os.Stat(deviceDir); err != nil {
+			stale = true
+		}
+	}
+	if stale {
+		devices = findAMDDevices()
+		devicesListed = time.Now()
+	}
+	return devices
+}
+
+// hasIoctlVRAM reports whether a card without mem_info_* sysfs files
+// still has VRAM according to the AMDGPU_INFO ioctl
+func hasIoctlVRAM(deviceDir string) bool {
+	node := RenderNode(deviceDir)
+	if node == "" {
+		return false
+	}
+	mi, err := queryAMDGPUMemory(node)
+	return err == nil && mi.vram.totalHeapSize > 0
+}
+
+// isVirtualFunction reports whether a device is an SR-IOV virtual function,
+// which links back to its physical function. Inside a guest the VF is all
+// there is and has no such link.
+func isVirtualFunction(deviceDir string) bool {
+	_, err := os.Lstat(filepath.Join(deviceDir, "physfn"))
+	return err == nil
+}
+
+// readMemDomains collects the used/total pairs of every mem_info_* file
+func readMemDomains(deviceDir string) []MemDomain {
+	files, _ := filepath.Glob(filepath.Join(deviceDir, "mem_info_*"))
+
+	byName := map[string]*MemDomain{}
+	var domains []*MemDomain
+	for _, f := range files {
+		name := strings.TrimPrefix(filepath.Base(f), "mem_info_")
+		var used bool
+		switch {
+		case strings.HasSuffix(name, "_used"):
+			name, used = strings.TrimSuffix(name, "_used"), true
+		case strings.HasSuffix(name, "_total"):
+			name = strings.TrimSuffix(name, "_total")
+		default:
+			continue // e.g. mem_info_vram_vendor isn't a size
+		}
+
+		d := byName[name]
+		if d == nil {
+			d = &MemDomain{Name: name}
+			byName[name] = d
+			domains = append(domains, d)
+		}
+		if used {
+			d.Used = readUint64(f)
+		} else {
+			d.Total = readUint64(f)
+		}
+	}
+
+	out := make([]MemDomain, len(domains))
+	for i, d := range domains {
+		out[i] = *d
+	}
+	return out
+}
+
+// GPUModel names a GPU device. Only some amdgpu cards expose product_name,
+// so fall back to the PCI id from uevent.
+func GPUModel(deviceDir string) string {
+	pciID := ReadUevent(deviceDir)["PCI_ID"]
+	name := readString(filepath.Join(deviceDir, "product_name"))
+	switch {
+	case name != "" && pciID != "":
+		return fmt.Sprintf("%s (%s)", name, pciID)
+	case name != "":
+		return name
+	case pciID != "":
+		return "PCI " + pciID
+	}
+	return "unknown"
+}
+
+// HwmonDir returns the hwmon directory of a GPU device, or "" if it has none
+func HwmonDir(deviceDir string) string {
+	dirs, _ := filepath.Glob(filepath.Join(deviceDir, "hwmon", "hwmon*"))
+	if len(dirs) == 0 {
+		return ""
+	}
+	return dirs[0]
+}
+
+// PCIAddress resolves a sysfs device directory to its PCI address,
+// e.g. "0000:03:00.0"
+func PCIAddress(deviceDir string) string {
+	real, err := filepath.EvalSymlinks(deviceDir)
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(real)
+}
+
+// ReadUevent parses the KEY=VALUE lines of a sysfs device's uevent file
+func ReadUevent(deviceDir string) map[string]string {
+	vals := map[string]string{}
+	data, err := os.ReadFile(filepath.Join(deviceDir, "uevent"))
+	if err != nil {
+		return vals
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if k, v, ok := strings.Cut(line, "="); ok {
+			vals[k] = v
+		}
+	}
+	return vals
+}
+
+// readString returns the trimmed contents of a sysfs file, or "" when it
+// can't be read or holds no real value
+func readString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	val := strings.TrimSpace(string(data))
+	if val == "Unknown" {
+		return ""
+	}
+	return val
+}
+
+var (
+	lastGoodMu sync.Mutex
+	lastGood   = map[string]uint64{} // path -> last successful reading
+)
+
+// readStableUint64 reads a sysfs counter, retrying briefly since reads can
+// fail for a moment during a GPU reset. If it still fails the previous
+// reading is returned, so the display doesn't flash to zero.
+func readStableUint64(path string) uint64 {
+	backoff := 5 * time.Millisecond
+	var err error
+	for attempt := 0; ; attempt++ {
+		var val uint64
+		val, err = parseUint64File(path)
+		if err == nil {
+			lastGoodMu.Lock()
+			lastGood[path] = val
+			lastGoodMu.Unlock()
+			return val
+		}
+		// A missing file won't appear on retry; a card without mem_info_*
+		// files is read through the ioctl fallback instead
+		if attempt == 2 || os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	if !os.IsNotExist(err) {
+		noteError("%v, showing the previous reading", err)
+	}
+
+	lastGoodMu.Lock()
+	defer lastGoodMu.Unlock()
+	return lastGood[path]
+}
+
+func parseUint64File(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func readUint64(path string) uint64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	val, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return val
+}
+
+type ProcessGPUInfo struct {
+	PID    int32  `json:"pid"`
+	Name   string `json:"name"`
+	VRAM   uint64 `json:"vram"`
+	GTT    uint64 `json:"gtt"`
+	RAM    uint64 `json:"ram"` // RSS minus GTT, see below
+	RSS    uint64 `json:"rss"` // raw resident set size
+	Swap   uint64 `json:"swap"`
+	Shared uint64 `json:"shared"` // dma-buf memory also held by other processes
+
+	// GPU the VRAM/GTT of this row lives on, empty when the process uses none.
+	// A process using several GPUs gets one row per card; its RAM and Shared
+	// are only reported on the first so totals aren't double-counted.
+	Card string `json:"card"`
+
+	UID int32   `json:"uid"` // real user id of the owner
+	CPU float64 `json:"cpu"` // percent of one core since the previous scan
+
+	// VCN decode / encode engine utilization percent since the previous scan
+	Decode float64 `json:"decode"`
+	Encode float64 `json:"encode"`
+
+	// Number of amdgpu fds the process holds on this card
+	Handles int `json:"handles"`
+
+	// Process start time (ms since epoch), to tell a reused PID apart
+	CreateTime int64 `json:"create_time"`
+
+	// Holds /dev/kfd open, i.e. is a ROCm/HIP compute client
+	ROCm bool `json:"rocm"`
+
+	// PID inside the process's own PID namespace when that isn't ours, e.g.
+	// in a container; 0 for processes in our namespace
+	NSPID int32 `json:"ns_pid"`
+
+	// Memory limit of the process's cgroup, 0 when unlimited. Like RAM, only
+	// set on the process's first row.
+	CgroupLimit uint64 `json:"cgroup_limit"`
+
+	// Kernel OOM-killer badness from /proc/<pid>/oom_score, higher is killed
+	// first, and the oom_score_adj bias (-1000 to 1000) that went into it;
+	// 0 when unreadable
+	OOMScore    int `json:"oom_score"`
+	OOMScoreAdj int `json:"oom_score_adj"`
+
+	// Resolved /proc/<pid>/exe, the binary actually running; empty when
+	// the link can't be read, e.g. for kernel threads or without privileges
+	Exe string `json:"exe,omitempty"`
+
+	// DRI device nodes the process's fds on this card were opened through,
+	// e.g. /dev/dri/renderD128
+	Nodes []string `json:"nodes,omitempty"`
+}
+
+// cardUsage accumulates a process's fds on one card
+type cardUsage struct {
+	vram    uint64
+	gtt     uint64
+	handles int
+	decNs   uint64 // cumulative VCN decode / encode busy time
+	encNs   uint64
+	nodes   []string
+}
+
+// fdInfo is what we learn from a single /proc/<pid>/fdinfo/<fd> entry.
+type fdInfo struct {
+	driver string // "amdgpu" or "xe", empty for other fds
+	pdev   string // PCI address of the GPU this fd belongs to
+
+	// DRM client (open file context) of the fd, 0 if not reported. Fds
+	// dup'ed, inherited or passed over a socket share one, with the same
+	// memory, however many of them a process holds.
+	clientID uint64
+	vram     uint64
+	gtt      uint64
+
+	// Cumulative busy time of the VCN media engines, in ns
+	decNs uint64
+	encNs uint64
+
+	// Set when the fd is a dma-buf, which may be shared across processes
+	dmabufIno  uint64
+	dmabufSize uint64
+
+	ino uint64 // inode of the open file, for any fd
+
+	hasMemory bool // a memory key this parser understands was present
+}
+
+// CanReadForeignFdinfo probes whether fdinfo of processes we don't own is
+// readable, which root, CAP_SYS_PTRACE or a relaxed ptrace scope all allow.
+// The first process owned by someone else decides; with none, nothing can
+// be denied. PID 1 is skipped, it is often non-dumpable and unreadable
+// even to root.
+func CanReadForeignFdinfo() bool {
+	self := uint32(os.Geteuid())
+	dirs, _ := filepath.Glob("/proc/[0-9]*")
+	for _, dir := range dirs {
+		fi, err := os.Stat(dir)
+		if err != nil || filepath.Base(dir) == "1" {
+			continue
+		}
+		if st, ok := fi.Sys().(*syscall.Stat_t); !ok || st.Uid == self {
+			continue
+		}
+
+		fds, err := os.ReadDir(filepath.Join(dir, "fdinfo"))
+		if os.IsNotExist(err) {
+			continue // exited meanwhile
+		}
+		if err != nil {
+			return false
+		}
+		if len(fds) > 0 {
+			_, err = os.ReadFile(filepath.Join(dir, "fdinfo", fds[0].Name()))
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err == nil
+		}
+		return true
+	}
+	return true
+}
+
+// GetProcessBreakdown lists processes using GPU memory or enough RAM to be
+// of interest. noMemoryKeys is set when GPU fds were found but none had
+// a memory key we know, i.e. this kernel's fdinfo can't be used for per
+// process GPU memory.
+func GetProcessBreakdown() (_ []ProcessGPUInfo, noMemoryKeys bool, err error) {
+	var results []ProcessGPUInfo
+	var dmabufs []map[uint64]uint64   // per result: dma-buf inode -> size
+	dmabufHolders := map[uint64]int{} // dma-buf inode -> number of processes holding it
+
+	// Only thread group leaders (TGIDs) are listed in /proc; threads live
+	// under /proc/<tgid>/task. They share the leader's fd table and address
+	// space, so GPU memory and RSS can't be assigned to single threads and
+	// each row is a whole process.
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, false, err
+	}
+	var inCgroup map[int32]bool
+	if CgroupDir != "" {
+		if inCgroup, err = cgroupPIDs(CgroupDir); err != nil {
+			return nil, false, err
+		}
+	}
+	var gpuFds, memoryFds int
+	cgroupLimits := map[string]uint64{}
+
+	minRSS := rssNoiseThreshold()
+
+	cardByPdev := map[string]string{}
+	for _, deviceDir := range AMDDevices() {
+		cardByPdev[PCIAddress(deviceDir)] = filepath.Base(filepath.Dir(deviceDir))
+	}
+	kfd := kfdInode()
+	ownPIDNS, _ := os.Readlink("/proc/self/ns/pid")
+
+	for _, p := range procs {
+		pid := p.Pid
+		if inCgroup != nil && !inCgroup[pid] {
+			continue
+		}
+		fds, err := readProcessFds(procRoot, pid, cardByPdev, kfd)
+		if err != nil {
+			continue // Likely permission denied or process ended
+		}
+		gpuFds += fds.gpuFds
+		memoryFds += fds.memoryFds
+		vram, gtt := fds.vram, fds.gtt
+		usage, cards, bufs, rocm := fds.usage, fds.cards, fds.bufs, fds.rocm
+		foundAMD := fds.gpuFds > 0
+
+		if foundAMD || true { // We want all processes or just AMD? Let's show all for context if they have RAM
+			// The process may have exited while we were reading its fds, in
+			// which case this fails and the fd counts are only partial
+			memInfo, err := p.MemoryInfo()
+			if err != nil {
+				noteError("pid %d exited during the scan: %v", pid, err)
+				continue
+			}
+			rss := memInfo.RSS
+
+			for ino := range bufs {
+				dmabufHolders[ino]++
+			}
+
+			// Only add if it uses some significant memory to avoid noise
+			// kfd allocations don't show up in DRM fdinfo on every kernel,
+			// so compute clients are listed even without visible VRAM
+			if vram > 0 || gtt > 0 || rocm || rss > minRSS {
+				createTime, _ := p.CreateTime()
+				uid := int32(-1)
+				if uids, err := p.Uids(); err == nil && len(uids) > 0 {
+					uid = uids[0]
+				}
+				cpu := cpuPercent(p, createTime)
+				cmdline, _ := p.Cmdline()
+				if cmdline == "" {
+					cmdline, _ = p.Name()
+				}
+				cmdline = sanitizeCmdline(cmdline)
+				exe, _ := p.Exe()
+				exe = sanitizeCmdline(exe)
+
+				// Subtract GTT from RAM for consistent reporting on unified systems
+				ram := rss
+				if ram > gtt {
+					ram -= gtt
+				} else {
+					ram = 0
+				}
+
+				oomScore := readProcInt(pid, "oom_score")
+				oomScoreAdj := readProcInt(pid, "oom_score_adj")
+
+				if len(cards) == 0 {
+					cards = []string{""}
+					usage[""] = &cardUsage{}
+				}
+				for i, card := range cards {
+					row := ProcessGPUInfo{
+						PID:         pid,
+						Name:        cmdline,
+						VRAM:        usage[card].vram,
+						GTT:         usage[card].gtt,
+						Card:        card,
+						Handles:     usage[card].handles,
+						UID:         uid,
+						CPU:         cpu,
+						CreateTime:  createTime,
+						ROCm:        rocm,
+						NSPID:       namespacedPID(pid, ownPIDNS),
+						Exe:         exe,
+						Nodes:       usage[card].nodes,
+						OOMScore:    oomScore,
+						OOMScoreAdj: oomScoreAdj,
+					}
+					row.Decode = engineUtil(row, "dec", usage[card].decNs)
+					row.Encode = engineUtil(row, "enc", usage[card].encNs)
+					rowBufs := map[uint64]uint64(nil)
+					if i == 0 {
+						row.RAM = ram
+						row.RSS = rss
+						row.Swap = readStatusKiB(pid, "VmSwap")
+						row.CgroupLimit = cgroupMemoryLimit(pid, cgroupLimits)
+						rowBufs = bufs
+					}
+					results = append(results, row)
+					dmabufs = append(dmabufs, rowBufs)
+				}
+			}
+		}
+	}
+
+	rates.endScan()
+
+	// A dma-buf held by more than one process is reported as shared rather
+	// than being silently counted once per holder
+	for i, bufs := range dmabufs {
+		for ino, size := range bufs {
+			if dmabufHolders[ino] > 1 {
+				results[i].Shared += size
+			}
+		}
+	}
+
+	return results, gpuFds > 0 && memoryFds == 0, nil
+}
+
+// processFds is what the fds of one process add up to
+type processFds struct {
+	vram, gtt uint64 // over all cards
+	usage     map[string]*cardUsage
+	cards     []string          // in first-seen order
+	bufs      map[uint64]uint64 // dma-buf inode -> size
+	rocm      bool              // has /dev/kfd open
+
+	gpuFds    int // distinct DRM clients
+	memoryFds int // of those, ones reporting memory keys
+}
+
+// readProcessFds reads the fdinfo of every fd of pid under root, a procfs
+// mount or a fixture standing in for one
+func readProcessFds(root string, pid int32, cardByPdev map[string]string, kfd uint64) (processFds, error) {
+	procDir := filepath.Join(root, strconv.Itoa(int(pid)))
+	fdinfoDir := filepath.Join(procDir, "fdinfo")
+	fds, err := os.ReadDir(fdinfoDir)
+	if err != nil {
+		return processFds{}, err
+	}
+
+	t := processFds{usage: map[string]*cardUsage{}, bufs: map[uint64]uint64{}}
+	clients := map[drmClient]bool{}
+	for _, fd := range fds {
+		info, ok := parseFdInfo(filepath.Join(fdinfoDir, fd.Name()))
+		// The inode is only a cheap hint, other filesystems reuse it
+		if kfd != 0 && info.ino == kfd && !t.rocm {
+			link, _ := os.Readlink(filepath.Join(procDir, "fd", fd.Name()))
+			t.rocm = link == "/dev/kfd"
+		}
+		if !ok {
+			continue
+		}
+		// card* and renderD* nodes of a GPU are separate clients with
+		// their own buffers; only fds of the same client repeat them
+		if info.driver != "" && info.clientID != 0 {
+			c := drmClient{info.pdev, info.clientID}
+			if clients[c] {
+				t.usage[cardOf(cardByPdev, info.pdev)].handles++
+				continue
+			}
+			clients[c] = true
+		}
+		if info.driver != "" {
+			t.gpuFds++
+			if info.hasMemory {
+				t.memoryFds++
+			}
+			t.vram += info.vram
+			t.gtt += info.gtt
+
+			card := cardOf(cardByPdev, info.pdev)
+			if t.usage[card] == nil {
+				t.usage[card] = &cardUsage{}
+				t.cards = append(t.cards, card)
+			}
+			t.usage[card].vram += info.vram
+			t.usage[card].gtt += info.gtt
+			t.usage[card].handles++
+			t.usage[card].decNs += info.decNs
+			t.usage[card].encNs += info.encNs
+			node, _ := os.Readlink(filepath.Join(procDir, "fd", fd.Name()))
+			if strings.HasPrefix(node, "/dev/dri/") && !slices.Contains(t.usage[card].nodes, node) {
+				t.usage[card].nodes = append(t.usage[card].nodes, node)
+			}
+		}
+		if info.dmabufIno != 0 {
+			// Several fds in one process can point at the same buffer
+			t.bufs[info.dmabufIno] = info.dmabufSize
+		}
+	}
+	return t, nil
+}
+
+// drmClient identifies a DRM client across a process's fds; client ids are
+// only unique per device
+type drmClient struct {
+	pdev string
+	id   uint64
+}
+
+// cardOf names the card at a PCI address, or falls back to the address for
+// GPUs that aren't amdgpu cards
+func cardOf(cardByPdev map[string]string, pdev string) string {
+	if card, known := cardByPdev[pdev]; known {
+		return card
+	}
+	return pdev
+}
+
+// engineUtil converts a cumulative engine busy time into the percentage of
+// time the engine was busy since the previous scan
+func engineUtil(p ProcessGPUInfo, engine string, busyNs uint64) float64 {
+	nsPerSec, _ := rates.rate(rateKey{p.PID, p.Card, p.CreateTime, engine}, float64(busyNs))
+	return nsPerSec / 1e9 * 100
+}
+
+// rssNoiseThreshold is the RSS below which non-GPU processes are left out.
+// It scales with the machine (0.1% of RAM) so small boards aren't flooded
+// and big servers aren't over-filtered.
+func rssNoiseThreshold() uint64 {
+	v, err := mem.VirtualMemory()
+	if err != nil {
+		return 1024 * 1024
+	}
+	return v.Total / 1000
+}
+
+// namespacedPID returns pid's PID in its innermost namespace if that isn't
+// ownNS, the namespace of this process, else 0
+func namespacedPID(pid int32, ownNS string) int32 {
+	ns, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(int(pid)), "ns", "pid"))
+	if err != nil || ownNS == "" || ns == ownNS {
+		return 0
+	}
+	// NSpid lists the PID in every nested namespace, outermost first
+	fields := strings.Fields(readStatusField(pid, "NSpid"))
+	if len(fields) < 2 {
+		return 0
+	}
+	inner, _ := strconv.ParseInt(fields[len(fields)-1], 10, 32)
+	return int32(inner)
+}
+
+// sanitizeCmdline makes a command line safe to print: any process can put
+// newlines, escape sequences or invalid UTF-8 in its arguments, which would
+// break the table layout or drive the terminal. Whitespace becomes a space,
+// anything else unprintable a Go-style escape such as \x1b.
+func sanitizeCmdline(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == utf8.RuneError && !strings.HasPrefix(s[i:], "\uFFFD"):
+			fmt.Fprintf(&b, "\\x%02x", s[i])
+		case unicode.IsSpace(r):
+			b.WriteByte(' ')
+		case !unicode.IsPrint(r):
+			q := strconv.QuoteRune(r)
+			b.WriteString(q[1 : len(q)-1])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// readProcInt reads a single-number file such as /proc/<pid>/oom_score, 0
+// if it can't be read
+func readProcInt(pid int32, name string) int {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(int(pid)), name))
+	if err != nil {
+		return 0
+	}
+	score, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return score
+}
+
+// readStatusKiB reads a "Key:   123 kB" field from /proc/<pid>/status, in bytes
+func readStatusKiB(pid int32, key string) uint64 {
+	v := readStatusField(pid, key)
+	return parseMemValue(strings.Fields(strings.Replace(v, "kB", "KiB", 1)))
+}
+
+// readStatusField returns the raw value of a /proc/<pid>/status field
+func readStatusField(pid int32, key string) string {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(int(pid)), "status"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if k, v, ok := strings.Cut(line, ":"); ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseMemValue parses a DRM fdinfo memory value such as "1234 KiB".
+// Per the DRM usage stats spec a value without a unit is in bytes.
+func parseMemValue(fields []string) uint64 {
+	if len(fields) == 0 {
+		return 0
+	}
+	val, _ := strconv.ParseUint(fields[0], 10, 64)
+	if len(fields) < 2 {
+		return val
+	}
+	switch fields[1] {
+	case "KiB":
+		return val * 1024
+	case "MiB":
+		return val * 1024 * 1024
+	case "GiB":
+		return val * 1024 * 1024 * 1024
+	}
+	return val
+}
+
+// sanitizeProcessUnits guards against drivers that label byte counts as
+// KiB. No process can hold more VRAM (or GTT) than the largest card has,
+// so a value above that must have been bytes we scaled up by 1024. Rows
+// of cards without amdgpu sysfs stats, e.g. xe ones, can't be checked.
+func sanitizeProcessUnits(procs []ProcessGPUInfo, gpus []GPUInfo) {
+	var maxVRAM, maxGTT uint64
+	known := map[string]bool{}
+	for _, g := range gpus {
+		maxVRAM = max(maxVRAM, g.VRAMTotal)
+		maxGTT = max(maxGTT, g.GTTTotal)
+		known[g.Card] = true
+	}
+
+	for i := range procs {
+		if !known[procs[i].Card] {
+			continue
+		}
+		if maxVRAM > 0 && procs[i].VRAM > maxVRAM {
+			procs[i].VRAM /= 1024
+		}
+		if maxGTT > 0 && procs[i].GTT > maxGTT {
+			procs[i].GTT /= 1024
+		}
+	}
+}
+
+// kfdInode is the inode of /dev/kfd, the ROCm compute device, or 0
+func kfdInode() uint64 {
+	fi, err := os.Stat("/dev/kfd")
+	if err != nil {
+		return 0
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return st.Ino
+	}
+	return 0
+}
+
+func parseFdInfo(path string) (info fdInfo, ok bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return info, false
+	}
+	defer file.Close()
+
+	var ino, size uint64
+	isDmabuf := false
+	var xeVRAM, xeGTT uint64
+	xeMemory := false
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Lines are "key:<whitespace>value"; kernels differ on whether that
+		// whitespace is a tab or spaces
+		key, rest, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch key {
+		case "drm-driver":
+			if fields[0] == "amdgpu" || fields[0] == "xe" {
+				info.driver = fields[0]
+			}
+		case "drm-pdev":
+			info.pdev = fields[0]
+		case "drm-client-id":
+			info.clientID, _ = strconv.ParseUint(fields[0], 10, 64)
+		case "drm-memory-vram":
+			info.vram += parseMemValue(fields)
+			info.hasMemory = true
+		case "drm-memory-gtt":
+			info.gtt += parseMemValue(fields)
+			info.hasMemory = true
+		// Summed like the memory keys, in case a client reports more than
+		// one line per engine
+		case "drm-engine-dec":
+			ns, _ := strconv.ParseUint(fields[0], 10, 64)
+			info.decNs += ns
+		case "drm-engine-enc", "drm-engine-enc_1":
+			ns, _ := strconv.ParseUint(fields[0], 10, 64)
+			info.encNs += ns
+
+		// dma-buf fds expose the exporter name, buffer size and inode
+		case "exp_name":
+			isDmabuf = true
+		case "ino":
+			ino, _ = strconv.ParseUint(fields[0], 10, 64)
+		case "size":
+			size, _ = strconv.ParseUint(fields[0], 10, 64)
+
+		default:
+			// xe has no drm-memory-* keys, only the generic per-region
+			// stats: vram0, vram1... are device memory, system and gtt
+			// are host memory the GPU maps, as GTT is on amdgpu. Stolen
+			// memory is RAM the firmware reserved for the GPU, which like
+			// VRAM the OS never sees as free.
+			region, isResident := strings.CutPrefix(key, "drm-resident-")
+			if !isResident {
+				break
+			}
+			switch {
+			case strings.HasPrefix(region, "vram"), region == "stolen":
+				xeVRAM += parseMemValue(fields)
+				xeMemory = true
+			case region == "system", region == "gtt":
+				xeGTT += parseMemValue(fields)
+				xeMemory = true
+			}
+		}
+	}
+	// amdgpu reports the same regions too on recent kernels, next to its
+	// drm-memory-* keys, so they're only used for xe
+	if info.driver == "xe" {
+		info.vram, info.gtt = xeVRAM, xeGTT
+		info.hasMemory = xeMemory
+	}
+	info.ino = ino
+	if isDmabuf && ino != 0 {
+		info.dmabufIno = ino
+		info.dmabufSize = size
+	}
+	return info, info.driver != "" || info.dmabufIno != 0
+}
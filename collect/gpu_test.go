@@ -0,0 +1,197 @@
+package collect
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseFdInfo(t *testing.T) {
+	const kib, mib = 1 << 10, 1 << 20
+	tests := []struct {
+		file   string
+		want   fdInfo
+		wantOK bool
+	}{
+		{
+			file: "amdgpu-tabs",
+			want: fdInfo{driver: "amdgpu", pdev: "0000:03:00.0", clientID: 27,
+				vram: 1048576 * kib, gtt: 2048 * kib, decNs: 1000, encNs: 200 + 30,
+				ino: 1114, hasMemory: true},
+			wantOK: true,
+		},
+		{
+			// Spaces instead of tabs, MiB and GiB; amdgpu's own
+			// drm-resident-* lines must not be counted on top
+			file: "amdgpu-spaces",
+			want: fdInfo{driver: "amdgpu", pdev: "0000:03:00.0", clientID: 31,
+				vram: 512 * mib, gtt: 1024 * mib, ino: 1115, hasMemory: true},
+			wantOK: true,
+		},
+		{
+			// No unit is bytes
+			file: "amdgpu-unitless",
+			want: fdInfo{driver: "amdgpu", pdev: "0000:03:00.0", clientID: 32,
+				vram: 4096, gtt: 8192, ino: 1116, hasMemory: true},
+			wantOK: true,
+		},
+		{
+			// Kernels before drm-memory-* still identify the GPU
+			file:   "amdgpu-no-memory",
+			want:   fdInfo{driver: "amdgpu", pdev: "0000:03:00.0", ino: 1117},
+			wantOK: true,
+		},
+		{
+			file: "amdgpu-two-decoders",
+			want: fdInfo{driver: "amdgpu", pdev: "0000:03:00.0", clientID: 33,
+				decNs: 1000 + 500, ino: 1118, hasMemory: true},
+			wantOK: true,
+		},
+		{
+			// Resident vram0 and stolen are device memory, resident system
+			// and gtt host memory; drm-total-* is what could be resident
+			file: "xe",
+			want: fdInfo{driver: "xe", pdev: "0000:00:02.0", clientID: 5,
+				vram: (262144 + 1024) * kib, gtt: (4096 + 8192) * kib,
+				ino: 2001, hasMemory: true},
+			wantOK: true,
+		},
+		{
+			file:   "dmabuf",
+			want:   fdInfo{ino: 4321, dmabufIno: 4321, dmabufSize: 8 * mib},
+			wantOK: true,
+		},
+		{
+			file: "regular-file",
+			want: fdInfo{ino: 987654},
+		},
+		{
+			file: "missing",
+		},
+	}
+	for _, tt := range tests {
+		got, ok := parseFdInfo(filepath.Join("testdata", "fdinfo", tt.file))
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: parseFdInfo = %+v, %v, want %+v, %v", tt.file, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestReadProcessFds(t *testing.T) {
+	cardByPdev := map[string]string{"0000:03:00.0": "card1", "0000:04:00.0": "card2"}
+	got, err := readProcessFds(filepath.Join("testdata", "proc"), 100, cardByPdev, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// fd 4 is a dup of fd 3, the same client whose buffers must count once.
+	// fd 8 has fd 3's client id on another card, which is another client.
+	want := processFds{
+		vram: (1024 + 2048 + 4096) << 10,
+		gtt:  (512 + 256) << 10,
+		usage: map[string]*cardUsage{
+			"card1": {vram: (1024 + 2048) << 10, gtt: 512 << 10, handles: 3, decNs: 1000 + 500, encNs: 10,
+				nodes: []string{"/dev/dri/card1", "/dev/dri/renderD128"}},
+			"card2": {vram: 4096 << 10, gtt: 256 << 10, handles: 1,
+				nodes: []string{"/dev/dri/renderD129"}},
+		},
+		cards: []string{"card1", "card2"},
+		// fds 6 and 7 are the same dma-buf
+		bufs:      map[uint64]uint64{4321: 8 << 20},
+		gpuFds:    3,
+		memoryFds: 3,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readProcessFds = %+v, want %+v", got, want)
+		for card, u := range got.usage {
+			t.Logf("%s: %+v", card, *u)
+		}
+	}
+
+	if _, err := readProcessFds(filepath.Join("testdata", "proc"), 999, cardByPdev, 0); err == nil {
+		t.Error("readProcessFds of a missing process succeeded, want an error")
+	}
+}
+
+func TestSanitizeCmdline(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/usr/bin/python3 train.py", "/usr/bin/python3 train.py"},
+		{"データ --in ファイル", "データ --in ファイル"},
+		// Arguments are NUL-separated in /proc, and any whitespace would
+		// break the row
+		{"a\tb\nc\rd", "a b c d"},
+		{"evil\x1b[2Jname", `evil\x1b[2Jname`},
+		{"bell\a", `bell\a`},
+		{"bad\xffutf8\xe3\x83", `bad\xffutf8\xe3\x83`},
+		// A real replacement character is left as it is
+		{"ok�", "ok�"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeCmdline(tt.in); got != tt.want {
+			t.Errorf("sanitizeCmdline(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeProcessUnits(t *testing.T) {
+	gpus := []GPUInfo{
+		{Card: "card0", VRAMTotal: 8 << 30, GTTTotal: 16 << 30},
+		{Card: "card1", VRAMTotal: 16 << 30, GTTTotal: 4 << 30},
+	}
+	procs := []ProcessGPUInfo{
+		// Within the largest card, left alone
+		{Card: "card0", VRAM: 12 << 30, GTT: 2 << 30},
+		// Bytes labelled KiB, scaled up 1024 times too many
+		{Card: "card1", VRAM: 1 << 40, GTT: 1 << 40},
+		// The largest card exactly
+		{Card: "card1", VRAM: 16 << 30, GTT: 16 << 30},
+		// No sysfs stats to check against, e.g. an xe card
+		{Card: "0000:00:02.0", VRAM: 1 << 40},
+	}
+	sanitizeProcessUnits(procs, gpus)
+
+	want := []ProcessGPUInfo{
+		{Card: "card0", VRAM: 12 << 30, GTT: 2 << 30},
+		{Card: "card1", VRAM: 1 << 30, GTT: 1 << 30},
+		{Card: "card1", VRAM: 16 << 30, GTT: 16 << 30},
+		{Card: "0000:00:02.0", VRAM: 1 << 40},
+	}
+	for i := range procs {
+		if procs[i].VRAM != want[i].VRAM || procs[i].GTT != want[i].GTT {
+			t.Errorf("row %d (%s): VRAM %d, GTT %d, want %d, %d", i, procs[i].Card,
+				procs[i].VRAM, procs[i].GTT, want[i].VRAM, want[i].GTT)
+		}
+	}
+
+	// Without any card totals nothing can be told apart
+	procs = []ProcessGPUInfo{{Card: "card0", VRAM: 1 << 40}}
+	sanitizeProcessUnits(procs, []GPUInfo{{Card: "card0"}})
+	if procs[0].VRAM != 1<<40 {
+		t.Errorf("without totals: VRAM %d, want %d", procs[0].VRAM, uint64(1<<40))
+	}
+}
+
+func TestScaleMiBReadings(t *testing.T) {
+	tests := []struct {
+		used, total         uint64
+		wantUsed, wantTotal uint64
+	}{
+		// Bytes, as almost every amdgpu version reports
+		{512 << 20, 8 << 30, 512 << 20, 8 << 30},
+		// A MiB count: no pool is under a MiB
+		{512, 8192, 512 << 20, 8 << 30},
+		{0, 1<<20 - 1, 0, (1<<20 - 1) << 20},
+		{0, 1 << 20, 0, 1 << 20},
+		// No total, e.g. missing file, is left for the caller
+		{123, 0, 123, 0},
+	}
+	for _, tt := range tests {
+		used, total := scaleMiBReadings(tt.used, tt.total)
+		if used != tt.wantUsed || total != tt.wantTotal {
+			t.Errorf("scaleMiBReadings(%d, %d) = %d, %d, want %d, %d",
+				tt.used, tt.total, used, total, tt.wantUsed, tt.wantTotal)
+		}
+	}
+}
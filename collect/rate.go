@@ -0,0 +1,84 @@
+package collect
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// rateTracker turns cumulative counters (CPU time, engine busy time, ...)
+// into per-second rates since the previous scan. Processes are re-listed
+// every scan, so the previous values are kept here.
+type rateTracker struct {
+	mu   sync.Mutex
+	prev map[rateKey]rateSample
+	scan int // incremented per scan, to prune processes that are gone
+}
+
+// rateKey identifies one counter of one process row; the create time
+// keeps a reused PID from inheriting another process's counter
+type rateKey struct {
+	pid        int32
+	card       string
+	createTime int64
+	counter    string
+}
+
+type rateSample struct {
+	value float64
+	at    time.Time
+	scan  int
+}
+
+var rates = &rateTracker{prev: map[rateKey]rateSample{}}
+
+// rate records value and returns how fast it grew per second since the
+// previous scan. ok is false the first time a counter is seen.
+func (r *rateTracker) rate(key rateKey, value float64) (perSec float64, ok bool) {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev, ok := r.prev[key]
+	r.prev[key] = rateSample{value: value, at: now, scan: r.scan}
+	if !ok {
+		return 0, false
+	}
+	elapsed := now.Sub(prev.at).Seconds()
+	if elapsed <= 0 || value < prev.value {
+		return 0, true
+	}
+	return (value - prev.value) / elapsed, true
+}
+
+// endScan forgets counters that weren't updated during the scan just done
+func (r *rateTracker) endScan() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, s := range r.prev {
+		if s.scan != r.scan {
+			delete(r.prev, k)
+		}
+	}
+	r.scan++
+}
+
+// cpuPercent returns p's CPU usage since the previous scan. A process seen
+// for the first time has no previous sample, so its lifetime average is
+// used; for long-running processes that can differ a lot from current usage.
+func cpuPercent(p *process.Process, createTime int64) float64 {
+	times, err := p.Times()
+	if err != nil {
+		return 0
+	}
+
+	key := rateKey{pid: p.Pid, createTime: createTime, counter: "cpu"}
+	perSec, ok := rates.rate(key, times.User+times.System)
+	if !ok {
+		pct, _ := p.CPUPercent()
+		return pct
+	}
+	return perSec * 100
+}
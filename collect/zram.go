@@ -0,0 +1,62 @@
+package collect
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ZramDevice is a compressed RAM block device, usually set up as swap.
+// Swap figures count what was swapped out uncompressed, while what it
+// actually costs is the far smaller MemUsed.
+type ZramDevice struct {
+	Name       string `json:"name"`       // e.g. "zram0"
+	DiskSize   uint64 `json:"disk_size"`  // capacity, in uncompressed bytes
+	Original   uint64 `json:"original"`   // data stored, uncompressed
+	Compressed uint64 `json:"compressed"` // the same data compressed
+	MemUsed    uint64 `json:"mem_used"`   // RAM taken, compressed data plus allocator overhead
+	Algorithm  string `json:"algorithm"`  // e.g. "zstd"
+}
+
+// readZram lists the configured zram devices; none is the common case and
+// not an error
+func readZram() []ZramDevice {
+	dirs, _ := filepath.Glob("/sys/block/zram[0-9]*")
+	var devs []ZramDevice
+	for _, dir := range dirs {
+		size, err := parseUint64File(filepath.Join(dir, "disksize"))
+		if err != nil || size == 0 {
+			continue // allocated by the module but never set up
+		}
+		dev := ZramDevice{Name: filepath.Base(dir), DiskSize: size, Algorithm: zramAlgorithm(dir)}
+
+		// "orig_data_size compr_data_size mem_used_total mem_limit ..."
+		data, err := os.ReadFile(filepath.Join(dir, "mm_stat"))
+		if err != nil {
+			noteError("%s: %v", dev.Name, err)
+			continue
+		}
+		stat := strings.Fields(string(data))
+		if len(stat) < 3 {
+			noteError("%s: short mm_stat %q", dev.Name, strings.TrimSpace(string(data)))
+			continue
+		}
+		dev.Original, _ = strconv.ParseUint(stat[0], 10, 64)
+		dev.Compressed, _ = strconv.ParseUint(stat[1], 10, 64)
+		dev.MemUsed, _ = strconv.ParseUint(stat[2], 10, 64)
+		devs = append(devs, dev)
+	}
+	return devs
+}
+
+// zramAlgorithm is the selected one of comp_algorithm's list, which reads
+// like "lzo lzo-rle lz4 [zstd]"
+func zramAlgorithm(dir string) string {
+	for _, alg := range strings.Fields(readString(filepath.Join(dir, "comp_algorithm"))) {
+		if strings.HasPrefix(alg, "[") {
+			return strings.Trim(alg, "[]")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	gaugeBoxStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("#7D56F4")).
+			Padding(0, 1)
+	gaugeFillStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.AdaptiveColor{Light: "#027A4F", Dark: "#04B575"})
+	gaugeWarnStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.AdaptiveColor{Light: "#B36B00", Dark: "#FFAA00"})
+	gaugeCritStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.AdaptiveColor{Light: "#C41E1E", Dark: "#FF4F4F"})
+	gaugeEmptyStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.AdaptiveColor{Light: "#CCCCCC", Dark: "#444444"})
+)
+
+// dashboardView renders large gauges centered in the terminal, for status
+// screens that don't need the process table
+func (m model) dashboardView() string {
+	// Gauges take most of the terminal width, within reason
+	barWidth := 60
+	if m.width > 0 {
+		barWidth = max(10, min(m.width*3/4, 120))
+	}
+
+	gauges := []string{
+		renderGauge("RAM", m.smoothed(m.hist.ram, m.usedRAM), m.totalRAM, barWidth, m.bands),
+		renderGauge("VRAM", m.smoothed(m.hist.vram, m.gpuInfo.VRAMUsed), m.gpuInfo.VRAMTotal, barWidth, m.bands),
+		renderGauge("GTT", m.smoothed(m.hist.gtt, m.gpuInfo.GTTUsed), m.gpuInfo.GTTTotal, barWidth, m.bands),
+	}
+	// Only cards that expose them have these
+	if g := m.gpuInfo; g.HasBusy {
+		gauges = append(gauges, renderBar("GPU", fmt.Sprintf("%.0f%% busy", g.BusyPercent), g.BusyPercent/100, barWidth, m.bands))
+	}
+	if g := m.gpuInfo; g.HasTemp {
+		limit := g.TempCrit
+		if limit <= 0 {
+			limit = 100 // a typical edge limit, for cards that don't say
+		}
+		gauges = append(gauges, renderBar("Temp", fmt.Sprintf("%.0f°C of %.0f°C", g.Temp, limit), g.Temp/limit, barWidth, m.bands))
+	}
+	head := []string{titleStyle.Render("Memory Monitor")}
+	if banner := m.oomBanner(); banner != "" {
+		head = append(head, strings.TrimSuffix(banner, "\n"))
+	}
+	content := lipgloss.JoinVertical(lipgloss.Center, append(head, gauges...)...)
+
+	if m.width == 0 || m.height == 0 {
+		return content + "\n"
+	}
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, content)
+}
+
+// severityBands are the usage percentages at which gauges turn yellow / red
+type severityBands struct {
+	warn float64
+	crit float64
+}
+
+func (b severityBands) style(percent float64) lipgloss.Style {
+	switch {
+	case percent >= b.crit:
+		return gaugeCritStyle
+	case percent >= b.warn:
+		return gaugeWarnStyle
+	}
+	return gaugeFillStyle
+}
+
+// renderGauge draws a labelled, two-row-high bar of used/total, colored by
+// which severity band the usage falls in
+func renderGauge(label string, used, total uint64, width int, bands severityBands) string {
+	frac := 0.0
+	if total > 0 {
+		frac = min(float64(used)/float64(total), 1)
+	}
+	return renderBar(label, fmt.Sprintf("%s / %s (%.1f%%)", formatBytes(used), formatBytes(total), frac*100), frac, width, bands)
+}
+
+// renderBar draws a gauge filled to frac, with detail next to the label
+func renderBar(label, detail string, frac float64, width int, bands severityBands) string {
+	frac = max(0, min(frac, 1))
+	filled := int(frac * float64(width))
+	row := bands.style(frac*100).Render(strings.Repeat("█", filled)) +
+		gaugeEmptyStyle.Render(strings.Repeat("░", width-filled))
+
+	heading := headerStyle.Render(label) + "  " + detail
+	return gaugeBoxStyle.Render(heading + "\n" + row + "\n" + row)
+}
+
+// miniBar draws val relative to maxVal as a bar of at most width cells,
+// using eighth blocks for sub-cell precision
+func miniBar(val, maxVal uint64, width int) string {
+	if maxVal == 0 || val == 0 {
+		return ""
+	}
+	eighths := int(float64(val) / float64(maxVal) * float64(width*8))
+	bar := strings.Repeat("█", eighths/8)
+	if rem := eighths % 8; rem > 0 {
+		bar += string([]rune("▏▎▍▌▋▊▉")[rem-1])
+	}
+	return bar
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"mem-monitor/collect"
+)
+
+// sysfsEntry is one raw sysfs value shown in the [i] detail panel
+type sysfsEntry struct {
+	name  string // path relative to the device directory
+	raw   string
+	value string // parsed for display, when we know the unit
+}
+
+// readSysfsDetail reads every memory, clock, temperature and power file of
+// a GPU device, for debugging numbers that look wrong
+func readSysfsDetail(deviceDir string) []sysfsEntry {
+	if deviceDir == "" {
+		return nil
+	}
+	patterns := []string{
+		"mem_info_*",
+		"pp_dpm_*",
+		"gpu_busy_percent",
+		"current_link_*",
+		"power_dpm_*",
+	}
+	if hwmon := collect.HwmonDir(deviceDir); hwmon != "" {
+		rel, _ := filepath.Rel(deviceDir, hwmon)
+		for _, p := range []string{"temp*_input", "temp*_label", "power*_average", "power*_input", "power*_cap", "freq*_input", "fan*_input", "pwm*"} {
+			patterns = append(patterns, filepath.Join(rel, p))
+		}
+	}
+
+	var entries []sysfsEntry
+	for _, pattern := range patterns {
+		files, _ := filepath.Glob(filepath.Join(deviceDir, pattern))
+		for _, f := range files {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				continue // write-only or permission denied
+			}
+			name, _ := filepath.Rel(deviceDir, f)
+			raw := strings.Join(strings.Fields(strings.ReplaceAll(strings.TrimSpace(string(data)), "\n", " | ")), " ")
+			entries = append(entries, sysfsEntry{name: name, raw: raw, value: parseSysfsValue(filepath.Base(f), raw)})
+		}
+	}
+	return entries
+}
+
+// parseSysfsValue converts values whose units we know to something readable
+func parseSysfsValue(file, raw string) string {
+	n, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case strings.HasPrefix(file, "mem_info_"):
+		return formatBytes(n)
+	case strings.HasPrefix(file, "temp"):
+		return fmt.Sprintf("%.1f °C", float64(n)/1000) // millidegrees
+	case strings.HasPrefix(file, "power"):
+		return fmt.Sprintf("%.1f W", float64(n)/1e6) // microwatts
+	case strings.HasPrefix(file, "freq"):
+		return fmt.Sprintf("%d MHz", n/1e6) // Hz
+	}
+	return ""
+}
+
+// detailView lists the raw sysfs values of the selected card
+func (m model) detailView() string {
+	title := "GPU sysfs Detail"
+	if m.gpuInfo.Card != "" {
+		title += fmt.Sprintf(" (%s)", m.gpuInfo.Card)
+	}
+	s := titleStyle.Render(title) + "\n\n"
+	if len(m.detail) == 0 {
+		s += "No readable sysfs files for this card.\n"
+	}
+
+	rows := m.detailRows()
+	start := min(m.detailOffset, m.maxDetailOffset()) // the window may have grown
+	end := min(start+rows, len(m.detail))
+	for _, e := range m.detail[start:end] {
+		line := fmt.Sprintf("%-32s %s", e.name, e.raw)
+		if e.value != "" {
+			line += infoStyle.Render("  (" + e.value + ")")
+		}
+		s += line + "\n"
+	}
+	if len(m.detail) > rows {
+		s += statusStyle.Render(fmt.Sprintf("lines %d-%d of %d", start+1, end, len(m.detail))) + "\n"
+	}
+	s += "\n" + m.keyHelp(detailKeys)
+	return s
+}
+
+// detailRows is how many entries fit in the panel
+func (m model) detailRows() int {
+	if m.height > 8 {
+		return m.height - 7
+	}
+	return 30
+}
+
+// maxDetailOffset is the offset that shows the last page of entries
+func (m model) maxDetailOffset() int {
+	return max(0, len(m.detail)-m.detailRows())
+}
+
+// refreshDetail re-reads the panel's entries. The list shrinks when files
+// turn unreadable, e.g. during a GPU reset, and is empty once the card is
+// gone, so the offset is clamped to what's left.
+func (m *model) refreshDetail() {
+	m.detail = readSysfsDetail(m.gpuInfo.DeviceDir)
+	m.detailOffset = min(m.detailOffset, m.maxDetailOffset())
+}
+
+// updateDetail handles keys while the detail panel is open
+func (m model) updateDetail(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "i", "esc":
+		m.showDetail = false
+	case "up", "k":
+		m.detailOffset = max(0, m.detailOffset-1)
+	case "down", "j":
+		m.detailOffset = min(m.detailOffset+1, m.maxDetailOffset())
+	}
+	return m, nil
+}
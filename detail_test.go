@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestDetailOffsetClamped(t *testing.T) {
+	// Scrolled to the end of a long list, then the card went away
+	m := model{height: 12, detail: make([]sysfsEntry, 25), detailOffset: 20}
+	m.refreshDetail()
+	if m.detail != nil || m.detailOffset != 0 {
+		t.Errorf("after the card went away: %d entries at offset %d, want none at 0", len(m.detail), m.detailOffset)
+	}
+
+	// A list that shrank without a re-read, e.g. on a bigger window, must
+	// still render
+	m = model{height: 12, detail: make([]sysfsEntry, 5), detailOffset: 20}
+	m.detailView()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// errorLogSize is how many recent errors the [e] panel keeps
+const errorLogSize = 100
+
+// loggedError is one entry of the [e] panel. The same error on
+// consecutive occasions is counted rather than repeated.
+type loggedError struct {
+	at    time.Time // last time it happened
+	msg   string
+	count int
+}
+
+// logErrors appends a snapshot's errors, dropping the oldest beyond
+// errorLogSize
+func (m *model) logErrors(at time.Time, errs []string) {
+	for _, msg := range errs {
+		if n := len(m.errLog); n > 0 && m.errLog[n-1].msg == msg {
+			m.errLog[n-1].at = at
+			m.errLog[n-1].count++
+			continue
+		}
+		m.errLog = append(m.errLog, loggedError{at: at, msg: msg, count: 1})
+	}
+	if over := len(m.errLog) - errorLogSize; over > 0 {
+		m.errLog = append([]loggedError(nil), m.errLog[over:]...)
+	}
+}
+
+// errorsView lists the logged errors, newest first
+func (m model) errorsView() string {
+	s := titleStyle.Render("Recent Errors") + "\n\n"
+	if len(m.errLog) == 0 {
+		s += "Nothing has gone wrong so far.\n"
+	}
+
+	rows := m.detailRows()
+	end := min(m.errorsOffset+rows, len(m.errLog))
+	for i := m.errorsOffset; i < end; i++ {
+		e := m.errLog[len(m.errLog)-1-i]
+		line := statusStyle.Render(e.at.Format(time.TimeOnly)) + " " + e.msg
+		if e.count > 1 {
+			line += statusStyle.Render(fmt.Sprintf(" (%d times)", e.count))
+		}
+		s += line + "\n"
+	}
+	if len(m.errLog) > rows {
+		s += statusStyle.Render(fmt.Sprintf("lines %d-%d of %d", m.errorsOffset+1, end, len(m.errLog))) + "\n"
+	}
+	s += "\n" + m.keyHelp(errorKeys)
+	return s
+}
+
+// updateErrors handles keys while the error panel is open
+func (m model) updateErrors(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "e", "esc":
+		m.showErrors = false
+	case "up", "k":
+		m.errorsOffset = max(0, m.errorsOffset-1)
+	case "down", "j":
+		m.errorsOffset = max(0, min(m.errorsOffset+1, len(m.errLog)-m.detailRows()))
+	}
+	return m, nil
+}
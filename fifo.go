@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+// fifoWriter writes whole lines to a named pipe without ever blocking on
+// its reader. Lines written while nobody has the FIFO open, or while the
+// reader is too slow to take them, are dropped; a reader that goes away
+// is waited for again, so consumers can come and go.
+type fifoWriter struct {
+	path    string
+	fd      int    // -1 while no reader is connected
+	partial []byte // start of a line whose end hasn't been written yet
+	pending []byte // rest of a line the reader hasn't taken yet
+}
+
+func newFIFOWriter(path string) *fifoWriter {
+	return &fifoWriter{path: path, fd: -1}
+}
+
+// isFIFO reports whether path is a named pipe
+func isFIFO(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode()&os.ModeNamedPipe != 0
+}
+
+// openOutput opens path for appending, or as a fifoWriter if it is a FIFO
+func openOutput(path string) (io.WriteCloser, error) {
+	if isFIFO(path) {
+		return newFIFOWriter(path), nil
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+}
+
+// Write never fails: what can't be delivered is dropped
+func (w *fifoWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.send(w.partial[:i+1])
+		w.partial = w.partial[i+1:]
+	}
+	// Don't keep the consumed prefix alive
+	w.partial = append([]byte(nil), w.partial...)
+	return len(p), nil
+}
+
+// send hands one line to the reader, unless the previous line is still
+// pending; a line is never split between readers or interleaved
+func (w *fifoWriter) send(line []byte) {
+	if w.fd < 0 {
+		// Without a reader the non-blocking open fails with ENXIO
+		fd, err := syscall.Open(w.path, syscall.O_WRONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+		if err != nil {
+			return
+		}
+		w.fd = fd
+	}
+	if w.flush(); len(w.pending) > 0 || w.fd < 0 {
+		return
+	}
+	w.pending = append([]byte(nil), line...)
+	w.flush()
+}
+
+// flush writes as much of the pending line as the pipe takes. Raw
+// syscalls, since an os.File would park on a full pipe instead of
+// returning EAGAIN.
+func (w *fifoWriter) flush() {
+	for len(w.pending) > 0 {
+		n, err := syscall.Write(w.fd, w.pending)
+		switch {
+		case errors.Is(err, syscall.EINTR):
+			continue
+		case errors.Is(err, syscall.EAGAIN):
+			return
+		case err != nil:
+			// EPIPE: the reader went away, wait for the next one
+			w.Close()
+			return
+		}
+		w.pending = w.pending[n:]
+	}
+}
+
+func (w *fifoWriter) Close() error {
+	if w.fd < 0 {
+		return nil
+	}
+	err := syscall.Close(w.fd)
+	w.fd = -1
+	w.pending = nil
+	return err
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"mem-monitor/collect"
+)
+
+// growthWindow is how many samples the GROWTH column's rate spans, enough
+// to smooth over a single noisy reading
+const growthWindow = 5
+
+type growthSample struct {
+	at    time.Time
+	total uint64 // VRAM + GTT + RAM
+}
+
+// trackGrowth records each process row's memory total of the latest scan
+func (m *model) trackGrowth(at time.Time) {
+	if m.growth == nil {
+		m.growth = map[leakKey][]growthSample{}
+	}
+	seen := make(map[leakKey]bool, len(m.scanned))
+	for _, p := range m.scanned {
+		k := leakKeyOf(p)
+		seen[k] = true
+		h := append(m.growth[k], growthSample{at, p.VRAM + p.GTT + p.RAM})
+		if len(h) > growthWindow {
+			h = h[1:]
+		}
+		m.growth[k] = h
+	}
+	for k := range m.growth {
+		if !seen[k] {
+			delete(m.growth, k)
+		}
+	}
+}
+
+// growthRate is how fast p's memory grew, in bytes per second over the
+// last growthWindow samples; negative when it shrank
+func (m model) growthRate(p collect.ProcessGPUInfo) float64 {
+	h := m.growth[leakKeyOf(p)]
+	if len(h) < 2 {
+		return 0
+	}
+	first, last := h[0], h[len(h)-1]
+	secs := last.at.Sub(first.at).Seconds()
+	if secs <= 0 {
+		return 0
+	}
+	return (float64(last.total) - float64(first.total)) / secs
+}
+
+// formatRate renders a signed rate such as "+1.2 MiB/s"
+func formatRate(perSec float64) string {
+	if perSec < 0 {
+		return "-" + formatBytes(uint64(math.Round(-perSec))) + "/s"
+	}
+	return "+" + formatBytes(uint64(math.Round(perSec))) + "/s"
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// historyWindows are the spans the sparklines can cover, cycled with [w]
+var historyWindows = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// history keeps the most recent samples of the headline numbers for the
+// sparklines. It is sized to cover the selected window at the current
+// refresh interval.
+type history struct {
+	capacity int
+	ram      []uint64
+	vram     []uint64 // of the selected card
+	gtt      []uint64
+
+	// [m] reference values of ram, vram and gtt the sparklines are compared
+	// against, nil when unset
+	ref []uint64
+}
+
+func newHistory(window, interval time.Duration) *history {
+	h := &history{}
+	h.resize(window, interval)
+	return h
+}
+
+// resize changes how many samples are kept, dropping the oldest if shrinking
+func (h *history) resize(window, interval time.Duration) {
+	h.capacity = max(2, int(window/interval))
+	h.ram = keepLast(h.ram, h.capacity)
+	h.vram = keepLast(h.vram, h.capacity)
+	h.gtt = keepLast(h.gtt, h.capacity)
+}
+
+func (h *history) add(ram, vram, gtt uint64) {
+	h.ram = keepLast(append(h.ram, ram), h.capacity)
+	h.vram = keepLast(append(h.vram, vram), h.capacity)
+	h.gtt = keepLast(append(h.gtt, gtt), h.capacity)
+}
+
+// resetGPU forgets the GPU samples, e.g. when another card is selected.
+// The reference goes too, it was taken on the other card.
+func (h *history) resetGPU() {
+	h.vram = nil
+	h.gtt = nil
+	h.ref = nil
+}
+
+// toggleRef freezes the latest samples as the reference, or clears it
+func (h *history) toggleRef() {
+	if h.ref != nil {
+		h.ref = nil
+		return
+	}
+	last := func(vals []uint64) uint64 {
+		if len(vals) == 0 {
+			return 0
+		}
+		return vals[len(vals)-1]
+	}
+	h.ref = []uint64{last(h.ram), last(h.vram), last(h.gtt)}
+}
+
+// defaultAverageSamples is what [a] averages over when --average isn't set
+const defaultAverageSamples = 10
+
+// smoothed is cur, or with averaging on the mean of the newest samples in
+// vals, the history of the same number
+func (m model) smoothed(vals []uint64, cur uint64) uint64 {
+	if !m.averaged || len(vals) == 0 {
+		return cur
+	}
+	vals = keepLast(vals, m.averageSamples)
+	var sum uint64
+	for _, v := range vals {
+		sum += v
+	}
+	return sum / uint64(len(vals))
+}
+
+// averageNote marks averaged values, e.g. " (avg of 10)"
+func (m model) averageNote() string {
+	if !m.averaged {
+		return ""
+	}
+	return fmt.Sprintf(" (avg of %d)", m.averageSamples)
+}
+
+func keepLast(vals []uint64, n int) []uint64 {
+	if len(vals) > n {
+		return vals[len(vals)-n:]
+	}
+	return vals
+}
+
+// sparkline draws vals relative to maxVal in width cells, padded. When
+// there are more samples than cells, each cell shows the mean of a bucket
+// of samples, so longer windows are rescaled to fit. With a non-zero ref,
+// every cell not above it, empty ones included, is drawn at the reference
+// level so it reads as a horizontal line, and only what rises above it
+// keeps its height, highlighted.
+func sparkline(vals []uint64, maxVal uint64, width int, ref uint64) string {
+	if maxVal == 0 {
+		return strings.Repeat(" ", width)
+	}
+	levels := []rune("▁▂▃▄▅▆▇█")
+	level := func(v float64) rune {
+		return levels[int(min(v/float64(maxVal), 1)*float64(len(levels)-1))]
+	}
+
+	cells := min(width, len(vals))
+	var b strings.Builder
+	for i := 0; i < cells; i++ {
+		lo, hi := i*len(vals)/cells, (i+1)*len(vals)/cells
+		var sum uint64
+		for _, v := range vals[lo:hi] {
+			sum += v
+		}
+		mean := float64(sum) / float64(hi-lo)
+		cell := string(level(mean))
+		switch {
+		case ref > 0 && mean > float64(ref):
+			cell = warnStyle.Render(cell)
+		case ref > 0:
+			cell = statusStyle.Render(string(level(float64(ref))))
+		}
+		b.WriteString(cell)
+	}
+	pad := strings.Repeat(" ", width-cells)
+	if ref > 0 {
+		pad = statusStyle.Render(strings.Repeat(string(level(float64(ref))), width-cells))
+	}
+	return b.String() + pad
+}
+
+// historyView renders a sparkline per headline number over the window
+func (m model) historyView() string {
+	window := historyWindows[m.historyWindow]
+	s := headerStyle.Render(fmt.Sprintf("History (last %s)", formatWindow(window))) + "\n"
+
+	const width = 60
+	line := func(i int, label string, vals []uint64, total uint64) string {
+		cur := uint64(0)
+		if len(vals) > 0 {
+			cur = m.smoothed(vals, vals[len(vals)-1])
+		}
+		ref, vsRef := uint64(0), ""
+		if m.hist.ref != nil {
+			ref = m.hist.ref[i]
+			vsRef = fmt.Sprintf(", %s vs reference", formatDelta(cur, ref))
+		}
+		return fmt.Sprintf("%-5s %s %s%s%s\n", label, sparkline(vals, total, width, ref), formatBytes(cur), m.averageNote(), vsRef)
+	}
+	s += line(0, "RAM", m.hist.ram, m.totalRAM)
+	s += line(1, "VRAM", m.hist.vram, m.gpuInfo.VRAMTotal)
+	s += line(2, "GTT", m.hist.gtt, m.gpuInfo.GTTTotal)
+	return s
+}
+
+func formatWindow(d time.Duration) string {
+	return strings.TrimSuffix(d.String(), "0s")
+}
@@ -0,0 +1,117 @@
+package main
+
+import "strings"
+
+// binding is one entry of the key help footer. The keymaps below are the
+// single place the footer is generated from, so a new key only needs its
+// case in Update and a line here.
+type binding struct {
+	group string // footer line the binding is listed on
+	keys  string
+	label func(m model) string
+	shown func(m model) bool // nil means always
+}
+
+func fixed(s string) func(model) string { return func(model) string { return s } }
+
+// toggled labels a binding by state, e.g. "Lock Order" vs "Unlock Order"
+func toggled(on func(m model) bool, off, onLabel string) func(model) string {
+	return func(m model) string {
+		if on(m) {
+			return onLabel
+		}
+		return off
+	}
+}
+
+func hasTable(m model) bool { return m.isPrivileged && len(m.scanned) > 0 }
+
+var tableKeys = []binding{
+	{"Move", "↑/↓ j/k", fixed("Row"), hasTable},
+	{"Move", "home/gg", fixed("Top"), hasTable},
+	{"Move", "end/G", fixed("Bottom"), hasTable},
+	{"Move", "tab", fixed("Next GPU"), func(m model) bool { return len(m.gpus) > 1 }},
+
+	{"Sort", "r", fixed("RAM"), hasTable},
+	{"Sort", "g", fixed("GTT"), hasTable},
+	{"Sort", "v", fixed("VRAM"), hasTable},
+	{"Sort", "s", fixed("Swap"), hasTable},
+	{"Sort", "p", fixed("CPU"), hasTable},
+	{"Sort", "O", fixed("OOM Score"), hasTable},
+	{"Sort", "u", fixed("Growth"), hasTable},
+	{"Sort", "l", toggled(func(m model) bool { return m.sortLocked }, "Lock Order", "Unlock Order"), hasTable},
+
+	{"Filter", "+/-", fixed("Min Memory"), hasTable},
+	{"Filter", "h", toggled(func(m model) bool { return m.rocmOnly }, "ROCm Only", "All Processes"),
+		func(m model) bool { return hasTable(m) && (m.rocmOnly || m.anyROCm()) }},
+	{"Filter", "f", fixed("Next Preset"), func(m model) bool { return hasTable(m) && len(m.presets) > 0 }},
+	{"Filter", "F", fixed("Save Preset"), hasTable},
+
+	{"View", "b", toggled(func(m model) bool { return m.baseline != nil }, "Baseline", "Clear Baseline"), hasTable},
+	{"View", "c", toggled(func(m model) bool { return m.shortNames }, "Program Names", "Full Cmdlines"), hasTable},
+	{"View", "t", toggled(func(m model) bool { return m.treeCollapsed == breakdownDepth }, "Collapse Breakdown", "Expand Breakdown"), nil},
+	{"View", "C", toggled(func(m model) bool { return m.showCommit }, "Commit Ratio", "Hide Commit Ratio"), nil},
+	{"View", "z", toggled(func(m model) bool { return m.dimIdle }, "Dim Idle", "Undim Idle"), hasTable},
+	{"View", "o", fixed("Reorder Columns"), hasTable},
+	{"View", "w", fixed("History Window"), nil},
+	{"View", "m", toggled(func(m model) bool { return m.hist.ref != nil }, "Mark Reference", "Clear Reference"), nil},
+	{"View", "a", toggled(func(m model) bool { return m.averaged }, "Averages", "Instant Values"), nil},
+	{"View", "d", toggled(func(m model) bool { return m.showDomains }, "Domains", "Hide Domains"), nil},
+	{"View", "i", fixed("sysfs Detail"), func(m model) bool { return m.gpuInfo.DeviceDir != "" }},
+	{"View", "R", fixed("Reconcile Totals"), nil},
+	{"View", "e", fixed("Errors"), func(m model) bool { return len(m.errLog) > 0 }},
+
+	{"Quit", "q", fixed(""), nil},
+}
+
+var reorderKeys = []binding{
+	{"Columns", "←/→", fixed("Select"), nil},
+	{"Columns", "</>", fixed("Move"), nil},
+	{"Columns", "space", fixed("Show/Hide"), nil},
+	{"Columns", "o", fixed("Done (order is saved)"), nil},
+}
+
+var namingKeys = []binding{
+	{"Preset", "enter", fixed("Save"), nil},
+	{"Preset", "esc", fixed("Cancel"), nil},
+}
+
+var detailKeys = []binding{
+	{"Scroll", "↑/↓", fixed("Line"), nil},
+	{"Close", "i/esc", fixed(""), nil},
+}
+
+var reconcileKeys = []binding{
+	{"Close", "R/esc", fixed(""), nil},
+}
+
+var errorKeys = []binding{
+	{"Scroll", "↑/↓", fixed("Line"), nil},
+	{"Close", "e/esc", fixed(""), nil},
+}
+
+// keyHelp renders the bindings that apply in the current state, one line
+// per group in the order the groups first appear
+func (m model) keyHelp(keymap []binding) string {
+	var groups []string
+	entries := map[string][]string{}
+	for _, b := range keymap {
+		if b.shown != nil && !b.shown(m) {
+			continue
+		}
+		if entries[b.group] == nil {
+			groups = append(groups, b.group)
+		}
+		entry := "[" + b.keys + "]"
+		if label := b.label(m); label != "" {
+			entry += " " + label
+		}
+		entries[b.group] = append(entries[b.group], entry)
+	}
+
+	var s strings.Builder
+	for _, g := range groups {
+		s.WriteString(g + ": " + strings.Join(entries[g], ", ") + "\n")
+	}
+	return s.String()
+}
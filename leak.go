@@ -0,0 +1,78 @@
+package main
+
+import (
+	"log"
+
+	"mem-monitor/collect"
+)
+
+// leakKey identifies a process row across ticks; the create time keeps a
+// reused PID from inheriting another process's history
+type leakKey struct {
+	procKey
+	createTime int64
+}
+
+// leakTracker flags processes whose VRAM grew on every one of the last
+// window ticks, a likely leak
+type leakTracker struct {
+	window  int
+	history map[leakKey][]uint64
+	leaking map[leakKey]bool
+	logger  *log.Logger // optional, logs each newly flagged process
+}
+
+func newLeakTracker(window int, logger *log.Logger) *leakTracker {
+	return &leakTracker{
+		window:  window,
+		history: map[leakKey][]uint64{},
+		leaking: map[leakKey]bool{},
+		logger:  logger,
+	}
+}
+
+func (t *leakTracker) observe(procs []collect.ProcessGPUInfo) {
+	seen := make(map[leakKey]bool, len(procs))
+	for _, p := range procs {
+		k := leakKeyOf(p)
+		seen[k] = true
+
+		h := append(t.history[k], p.VRAM)
+		if len(h) > t.window {
+			h = h[1:]
+		}
+		t.history[k] = h
+
+		leaking := len(h) == t.window && monotonicallyIncreasing(h)
+		if leaking && !t.leaking[k] && t.logger != nil {
+			t.logger.Printf("possible VRAM leak: process %d %s grew from %s to %s over %d samples",
+				p.PID, p.Name, formatBytes(h[0]), formatBytes(h[len(h)-1]), len(h))
+		}
+		t.leaking[k] = leaking
+	}
+
+	// Forget processes that are gone
+	for k := range t.history {
+		if !seen[k] {
+			delete(t.history, k)
+			delete(t.leaking, k)
+		}
+	}
+}
+
+func (t *leakTracker) isLeaking(p collect.ProcessGPUInfo) bool {
+	return t.leaking[leakKeyOf(p)]
+}
+
+func leakKeyOf(p collect.ProcessGPUInfo) leakKey {
+	return leakKey{procKey{p.PID, p.Card}, p.CreateTime}
+}
+
+func monotonicallyIncreasing(vals []uint64) bool {
+	for i := 1; i < len(vals); i++ {
+		if vals[i] <= vals[i-1] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,96 +1,600 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
+	"unicode/utf8"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/muesli/termenv"
+
+	"mem-monitor/collect"
 )
 
 type model struct {
-	totalRAM     uint64
-	usedRAM      uint64
-	gpuInfo      GPUInfo
-	processes    []ProcessGPUInfo
-	err          error
-	isPrivileged bool
-	sortBy       string // "RAM", "GTT", "VRAM"
+	totalRAM        uint64
+	usedRAM         uint64
+	availRAM        uint64 // MemAvailable, for the OOM-risk banner
+	freeRAM         uint64
+	cgroupMem       *collect.CgroupMemory // nil outside a memory-limited cgroup without --cgroup
+	committedAS     uint64
+	commitLimit     uint64
+	swapTotal       uint64
+	swapUsed        uint64
+	zram            []collect.ZramDevice
+	showCommit      bool // [C] show the overcommit ratio in the breakdown
+	treeCollapsed   int  // [t] breakdown levels hidden from the bottom up
+	slabReclaimable uint64
+	slabUnreclaim   uint64
+	gpuInfo         collect.GPUInfo // stats of the selected card
+	gpus            []collect.GPUInfo
+	selectedGPU     int
+	pinnedGPU       string                   // PCI address of the selected card, kept across index changes
+	processes       []collect.ProcessGPUInfo // rows passing minMem, in display order
+	scanned         []collect.ProcessGPUInfo // every row of the last scan
+	noFdinfoMemory  bool                     // this kernel's fdinfo lacks per-process GPU memory
+	minMem          uint64                   // [+]/[-] hide rows using less memory than this
+	rocmOnly        bool                     // [h] only list ROCm/HIP compute clients
+	presets         []filterPreset
+	preset          int  // index of the last applied preset, -1 for none
+	naming          bool // [F] typing the name to save the filters under
+	nameInput       string
+	err             error
+	isPrivileged    bool   // other users' fdinfo is readable, see collect.CanReadForeignFdinfo
+	sortBy          string // "RAM", "GTT", "VRAM", "SWAP", "CPU", "OOM", "GROWTH"
+	sortLocked      bool   // keep the current row order, only refresh the values
+	interval        time.Duration
+	askedInterval   time.Duration // --interval, before any backoff for slow scans
+	slowScans       int           // consecutive scans that took longer than the interval
+	exitAfter       time.Duration // quit after this long, if set
+	scanTime        time.Duration // how long the last GPU + process gather took
+	lastUpdate      time.Time     // when the displayed data was collected
+	scanning        bool          // a gather is in flight, don't start another
+	pauseUnfocused  bool          // stop gathering while the terminal is in the background
+	setTitle        bool          // keep the terminal title on the headline usage
+	unfocused       bool
+	dashboard       bool  // gauge-only layout
+	verbose         bool  // show raw RSS next to the adjusted RAM
+	showNodes       bool  // show the DRI node of each process's fds
+	showExe         bool  // show the resolved executable path
+	showDomains     bool  // expand every mem_info_* pool of the card
+	highlightUID    int32 // rows owned by this user stand out, -1 for none
+	dimIdle         bool  // fade rows without VRAM or GTT
+	shortNames      bool  // [c] show program names instead of full cmdlines
+	noColor         bool
+	bands           severityBands
+	oomThreshold    float64        // available RAM percent below which the OOM banner shows
+	remote          *remoteStream  // data source when monitoring another machine
+	spikes          *spikeDetector // nil unless anomaly logging is enabled
+	leaks           *leakTracker   // nil unless leak detection is enabled
+	report          *sessionReport // nil unless --report is set
+	sqlite          *sqliteSink    // nil unless --sqlite is set
+	width           int
+	height          int
+
+	hist          *history
+	historyWindow int // index into historyWindows
+
+	// [a] shows headline numbers as the mean of the last averageSamples
+	// instead of the latest sample
+	averaged       bool
+	averageSamples int
+
+	columns        []string        // process table column ids, in display order
+	reordering     bool            // [o] column reorder mode
+	selectedColumn int             // index into the visible columns while reordering
+	columnToggles  map[string]bool // column id -> turned on, where changed from its default
+
+	// Process list scrolling: cursor is the selected row, offset the first
+	// row displayed
+	cursor      int
+	offset      int
+	pendingG    bool   // first g of a vi-style "gg" was pressed
+	sortBeforeG string // sort column the pending g replaced, restored by gg
+
+	// [i] panel with the raw sysfs values of the selected card
+	showDetail   bool
+	detail       []sysfsEntry
+	detailOffset int
+
+	showReconcile bool // [R] screen reconciling card and process totals
+
+	// [e] panel with recent non-fatal errors, oldest first
+	errLog       []loggedError
+	showErrors   bool
+	errorsOffset int
+
+	// Recent memory totals per process row, for the GROWTH column
+	growth map[leakKey][]growthSample
+
+	// PIDs of the previous scan, and when processes new since then arrived
+	prevPIDs map[int32]bool
+	arrived  map[int32]time.Time
+
+	// Per-row snapshot captured with [b]; while set the table shows deltas
+	baseline map[procKey]collect.ProcessGPUInfo
+}
+
+// procKey identifies a process table row, which is per process per card
+type procKey struct {
+	PID  int32
+	Card string
 }
 
 type tickMsg struct {
-	totalRAM  uint64
-	usedRAM   uint64
-	gpuInfo   GPUInfo
-	processes []ProcessGPUInfo
-	err       error
+	collect.Snapshot
+	err error
 }
 
 func (m model) Init() tea.Cmd {
-	return tick()
+	cmds := []tea.Cmd{tick(m.interval)}
+	if m.remote != nil {
+		cmds = append(cmds, m.remote.next)
+	}
+	if m.exitAfter > 0 {
+		cmds = append(cmds, tea.Tick(m.exitAfter, func(time.Time) tea.Msg { return tea.QuitMsg{} }))
+	}
+	return tea.Batch(cmds...)
 }
 
-func tick() tea.Cmd {
-	return tea.Every(time.Second, func(t time.Time) tea.Msg {
-		v, err := mem.VirtualMemory()
-		if err != nil {
-			return tickMsg{err: err}
-		}
-
-		gpu, _ := GetGPUStats()
-		procs, _ := GetProcessBreakdown()
+// refreshMsg fires every interval; a gather is only started from it when
+// the previous one has finished, so slow scans can't pile up
+type refreshMsg time.Time
 
-		return tickMsg{
-			totalRAM:  v.Total,
-			usedRAM:   v.Used,
-			gpuInfo:   gpu,
-			processes: procs,
-		}
+func tick(interval time.Duration) tea.Cmd {
+	return tea.Every(interval, func(t time.Time) tea.Msg {
+		return refreshMsg(t)
 	})
 }
 
+func gatherCmd() tea.Msg {
+	snap, err := collectSnapshot()
+	return tickMsg{Snapshot: snap, err: err}
+}
+
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+	case tea.BlurMsg:
+		m.unfocused = true
+	case tea.FocusMsg:
+		m.unfocused = false
+		// Catch up right away rather than showing stale data until the tick
+		if m.pauseUnfocused && !m.scanning && m.remote == nil {
+			m.scanning = true
+			return m, gatherCmd
+		}
 	case tea.KeyMsg:
-		switch msg.String() {
+		if m.reordering {
+			return m.updateReorder(msg)
+		}
+		if m.showDetail {
+			return m.updateDetail(msg)
+		}
+		if m.showErrors {
+			return m.updateErrors(msg)
+		}
+		if m.showReconcile {
+			return m.updateReconcile(msg)
+		}
+		if m.naming {
+			return m.updateNaming(msg)
+		}
+		key := msg.String()
+		gg := key == "g" && m.pendingG
+		m.pendingG = key == "g" && !gg
+
+		switch key {
 		case "q", "ctrl+c":
 			return m, tea.Quit
+		case "up", "k":
+			m.moveCursor(-1)
+		case "down", "j":
+			m.moveCursor(1)
+		case "home":
+			m.moveCursor(-len(m.processes))
+		case "end", "G":
+			m.moveCursor(len(m.processes))
 		case "r":
 			m.sortBy = "RAM"
 		case "g":
-			m.sortBy = "GTT"
+			// A lone g sorts by GTT right away; completing gg undoes that
+			// and only jumps to the top
+			if gg {
+				m.sortBy = m.sortBeforeG
+				m.moveCursor(-len(m.processes))
+			} else {
+				m.sortBeforeG = m.sortBy
+				m.sortBy = "GTT"
+			}
 		case "v":
 			m.sortBy = "VRAM"
+		case "s":
+			m.sortBy = "SWAP"
+		case "p":
+			m.sortBy = "CPU"
+		case "O":
+			m.sortBy = "OOM"
+		case "u":
+			m.sortBy = "GROWTH"
+		case "tab":
+			if len(m.gpus) > 0 {
+				m.selectedGPU = (m.selectedGPU + 1) % len(m.gpus)
+				m.gpuInfo = m.gpus[m.selectedGPU]
+				m.pinnedGPU = m.gpuInfo.PCI
+				m.resetGPUHistory()
+			}
+		case "w":
+			m.historyWindow = (m.historyWindow + 1) % len(historyWindows)
+			m.hist.resize(historyWindows[m.historyWindow], m.interval)
+		case "d":
+			m.showDomains = !m.showDomains
+		case "z":
+			m.dimIdle = !m.dimIdle
+		case "a":
+			m.averaged = !m.averaged
+		case "c":
+			m.shortNames = !m.shortNames
+		case "C":
+			m.showCommit = !m.showCommit
+		case "t":
+			m.treeCollapsed = (m.treeCollapsed + 1) % (breakdownDepth + 1)
+		case "m":
+			m.hist.toggleRef()
+		case "h":
+			m.rocmOnly = !m.rocmOnly
+			m.applyFilters()
+		case "f":
+			m.nextPreset()
+		case "F":
+			m.naming = true
+			m.nameInput = m.presetName()
+		case "+", "=":
+			m.minMem = max(minMemStep, m.minMem*2)
+			m.applyFilters()
+		case "-":
+			m.minMem /= 2
+			if m.minMem < minMemStep {
+				m.minMem = 0
+			}
+			m.applyFilters()
+		case "i":
+			if m.gpuInfo.DeviceDir != "" {
+				m.showDetail = true
+				m.detailOffset = 0
+				m.refreshDetail()
+			}
+		case "R":
+			m.showReconcile = true
+		case "e":
+			m.showErrors = true
+			m.errorsOffset = 0
+		case "o":
+			m.reordering = true
+			m.selectedColumn = 0
+		case "l":
+			m.sortLocked = !m.sortLocked
+		case "b":
+			if m.baseline != nil {
+				m.baseline = nil
+			} else {
+				m.baseline = make(map[procKey]collect.ProcessGPUInfo, len(m.scanned))
+				for _, p := range m.scanned {
+					m.baseline[procKey{p.PID, p.Card}] = p
+				}
+			}
+		}
+	case refreshMsg:
+		// Remote snapshots arrive on their own; the tick just keeps the
+		// "updated ago" display current
+		if m.scanning || m.remote != nil || m.paused() {
+			return m, tick(m.interval)
 		}
+		m.scanning = true
+		return m, tea.Batch(tick(m.interval), gatherCmd)
 	case tickMsg:
+		m.scanning = false
 		if msg.err != nil {
 			m.err = msg.err
 		} else {
-			m.totalRAM = msg.totalRAM
-			m.usedRAM = msg.usedRAM
-			m.gpuInfo = msg.gpuInfo
-			m.processes = msg.processes
-
-			sort.Slice(m.processes, func(i, j int) bool {
-				switch m.sortBy {
-				case "RAM":
-					return m.processes[i].RAM > m.processes[j].RAM
-				case "VRAM":
-					return m.processes[i].VRAM > m.processes[j].VRAM
-				default: // GTT is default
-					return m.processes[i].GTT > m.processes[j].GTT
+			m.totalRAM = msg.TotalRAM
+			m.usedRAM = msg.UsedRAM
+			m.availRAM = msg.AvailableRAM
+			m.freeRAM = msg.FreeRAM
+			m.cgroupMem = msg.Cgroup
+			m.committedAS = msg.CommittedAS
+			m.commitLimit = msg.CommitLimit
+			m.swapTotal, m.swapUsed = msg.SwapTotal, msg.SwapUsed
+			m.zram = msg.Zram
+			m.slabReclaimable = msg.SlabReclaimable
+			m.slabUnreclaim = msg.SlabUnreclaim
+			m.gpus = msg.GPUs
+			// Card indexes can shift when a GPU is plugged in or removed; the
+			// PCI address doesn't, so follow the selected card by that
+			prevPCI := m.gpuInfo.PCI
+			want := m.pinnedGPU
+			if want == "" {
+				want = prevPCI
+			}
+			if want != "" {
+				if i := collect.FindGPUByPCI(m.gpus, want); i >= 0 {
+					m.selectedGPU = i
+				}
+			}
+			if m.selectedGPU >= len(m.gpus) {
+				m.selectedGPU = 0
+			}
+			m.gpuInfo = collect.GPUInfo{}
+			if len(m.gpus) > 0 {
+				m.gpuInfo = m.gpus[m.selectedGPU]
+			}
+			if prevPCI != "" && m.gpuInfo.PCI != prevPCI {
+				m.resetGPUHistory() // the selected card went away
+			}
+			m.scanned = msg.Processes
+			m.noFdinfoMemory = msg.NoFdinfoMemory
+			m.scanTime = msg.ScanTime
+			m.backOffSlowScans()
+			m.lastUpdate = msg.Timestamp
+			m.logErrors(msg.Timestamp, msg.Errors)
+			m.trackArrivals(msg.Timestamp)
+			m.trackGrowth(msg.Timestamp)
+
+			if m.showDetail {
+				m.refreshDetail()
+			}
+			m.hist.add(m.usedRAM, m.gpuInfo.VRAMUsed, m.gpuInfo.GTTUsed)
+
+			if m.spikes != nil {
+				// Not logged anywhere, so list it with the errors
+				if spike := m.spikes.observe(m.gpuInfo, m.scanned); spike != "" && m.spikes.logger == nil {
+					m.logErrors(msg.Timestamp, []string{spike})
+				}
+			}
+			if m.leaks != nil {
+				m.leaks.observe(m.scanned)
+			}
+			if m.report != nil {
+				m.report.observe(m.lastUpdate, m.totalRAM, m.usedRAM, m.gpuInfo, m.scanned)
+			}
+			if m.sqlite != nil {
+				if err := m.sqlite.observe(m.lastUpdate, m.scanned); err != nil {
+					m.err = err
 				}
-			})
+			}
+
+			m.applyFilters()
+			var cmds []tea.Cmd
+			if m.setTitle {
+				cmds = append(cmds, tea.SetWindowTitle(m.windowTitle()))
+			}
+			if m.remote != nil {
+				cmds = append(cmds, m.remote.next)
+			}
+			return m, tea.Batch(cmds...)
 		}
-		return m, tick()
 	}
 	return m, nil
 }
 
+// slowScansBeforeBackoff is how many scans in a row may overrun the
+// interval before it is raised; a single slow one can be a hiccup
+const slowScansBeforeBackoff = 3
+
+// backOffSlowScans raises the refresh interval when scans consistently take
+// longer than it, as on hosts with thousands of processes, so the display
+// doesn't fall further behind every tick
+func (m *model) backOffSlowScans() {
+	if m.remote != nil {
+		return // the remote scans on its own schedule
+	}
+	if m.scanTime <= m.interval {
+		m.slowScans = 0
+		return
+	}
+	if m.slowScans++; m.slowScans < slowScansBeforeBackoff {
+		return
+	}
+	m.slowScans = 0
+	// Half again the scan time leaves the UI some breathing room
+	next := (m.scanTime * 3 / 2).Truncate(100*time.Millisecond) + 100*time.Millisecond
+	m.interval = max(m.interval, next)
+	m.hist.resize(historyWindows[m.historyWindow], m.interval)
+}
+
+// windowTitle is the compact summary --title puts in the terminal title
+func (m model) windowTitle() string {
+	percent := func(used, total uint64) string {
+		if total == 0 {
+			return "n/a"
+		}
+		return fmt.Sprintf("%.0f%%", float64(used)/float64(total)*100)
+	}
+	return fmt.Sprintf("RAM %s | VRAM %s | GTT %s", percent(m.usedRAM, m.totalRAM),
+		percent(m.gpuInfo.VRAMUsed, m.gpuInfo.VRAMTotal), percent(m.gpuInfo.GTTUsed, m.gpuInfo.GTTTotal))
+}
+
+// paused reports whether refreshing is on hold because the terminal lost
+// focus. Terminals that don't report focus never send a blur.
+func (m model) paused() bool {
+	return m.pauseUnfocused && m.unfocused
+}
+
+// minMemStep is the smallest non-zero [+]/[-] filter; each press doubles
+// or halves it from there
+const minMemStep = 1 << 20
+
+// applyFilters rebuilds the displayed rows from the last scan, keeping
+// those whose VRAM, GTT and RAM add up to at least minMem, and only ROCm
+// clients when rocmOnly is set
+func (m *model) applyFilters() {
+	prev := m.processes
+	m.processes = make([]collect.ProcessGPUInfo, 0, len(m.scanned))
+	for _, p := range m.scanned {
+		if p.VRAM+p.GTT+p.RAM >= m.minMem && (p.ROCm || !m.rocmOnly) {
+			m.processes = append(m.processes, p)
+		}
+	}
+	m.sortProcesses(prev)
+	m.moveCursor(0) // the list may have shrunk
+}
+
+// processStats summarizes GPU usage over all processes, not just the rows
+// that fit on screen or pass the memory filter
+func (m model) processStats() string {
+	pids := map[int32]bool{}
+	var total, maxVRAM uint64
+	for _, p := range m.scanned {
+		if p.VRAM == 0 && p.GTT == 0 {
+			continue
+		}
+		pids[p.PID] = true
+		total += p.VRAM
+		maxVRAM = max(maxVRAM, p.VRAM)
+	}
+
+	mean := uint64(0)
+	if len(pids) > 0 {
+		mean = total / uint64(len(pids))
+	}
+	return fmt.Sprintf("GPU processes: %d | VRAM per process: mean %s, max %s | VRAM accounted for: %s",
+		len(pids), formatBytes(mean), formatBytes(maxVRAM), formatBytes(total))
+}
+
+// processVRAM sums the VRAM of the selected card's process rows; more than
+// the card itself reports means something is counted twice
+func (m model) processVRAM() uint64 {
+	var sum uint64
+	for _, p := range m.scanned {
+		if p.Card == m.gpuInfo.Card {
+			sum += p.VRAM
+		}
+	}
+	return sum
+}
+
+// resetGPUHistory forgets what was recorded about the previously selected
+// card, when another one is selected
+func (m *model) resetGPUHistory() {
+	m.hist.resetGPU()
+	if m.spikes != nil {
+		m.spikes.reset()
+	}
+}
+
+// tableRows is how many process rows are displayed at once
+const tableRows = 15
+
+// moveCursor moves the selected row by delta, clamped to the list, and
+// scrolls so it stays in view
+func (m *model) moveCursor(delta int) {
+	m.cursor = max(0, min(m.cursor+delta, len(m.processes)-1))
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+tableRows {
+		m.offset = m.cursor - tableRows + 1
+	}
+	m.offset = max(0, min(m.offset, len(m.processes)-tableRows))
+}
+
+// updateReorder handles keys while rearranging table columns
+func (m model) updateReorder(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "o", "esc", "enter":
+		m.reordering = false
+	case "left":
+		m.selectedColumn = max(0, m.selectedColumn-1)
+	case "right":
+		m.selectedColumn = min(len(m.visibleColumns())-1, m.selectedColumn+1)
+	case "<", "shift+left":
+		m.moveColumn(-1)
+		m.saveColumns()
+	case ">", "shift+right":
+		m.moveColumn(1)
+		m.saveColumns()
+	case " ":
+		m.toggleColumn()
+		m.saveColumns()
+	}
+	return m, nil
+}
+
+func (m model) saveColumns() {
+	st := loadState()
+	st.Columns = m.columns
+	st.ShownColumns = m.columnToggles
+	saveState(st) // best effort, the new layout still applies this session
+}
+
+// sortProcesses orders m.processes by the sort column. While the order is
+// locked, rows keep their position from prev and only new rows are sorted,
+// after the existing ones.
+func (m model) sortProcesses(prev []collect.ProcessGPUInfo) {
+	less := func(a, b collect.ProcessGPUInfo) bool {
+		switch m.sortBy {
+		case "RAM":
+			return a.RAM > b.RAM
+		case "VRAM":
+			return a.VRAM > b.VRAM
+		case "SWAP":
+			return a.Swap > b.Swap
+		case "CPU":
+			return a.CPU > b.CPU
+		case "OOM":
+			return a.OOMScore > b.OOMScore
+		case "GROWTH":
+			return m.growthRate(a) > m.growthRate(b)
+		default: // GTT is default
+			return a.GTT > b.GTT
+		}
+	}
+
+	if !m.sortLocked {
+		sort.Slice(m.processes, func(i, j int) bool {
+			return less(m.processes[i], m.processes[j])
+		})
+		return
+	}
+
+	rank := make(map[procKey]int, len(prev))
+	for i, p := range prev {
+		rank[procKey{p.PID, p.Card}] = i
+	}
+	sort.SliceStable(m.processes, func(i, j int) bool {
+		ri, iKnown := rank[procKey{m.processes[i].PID, m.processes[i].Card}]
+		rj, jKnown := rank[procKey{m.processes[j].PID, m.processes[j].Card}]
+		switch {
+		case iKnown && jKnown:
+			return ri < rj
+		case iKnown != jKnown:
+			return iKnown
+		}
+		return less(m.processes[i], m.processes[j])
+	})
+}
+
+// Colors that would be unreadable on a light background are adaptive; lipgloss
+// picks the variant from the detected terminal background unless --theme
+// overrides it.
 var (
 	titleStyle = lipgloss.NewStyle().
 			Bold(true).
@@ -105,107 +609,709 @@ var (
 				Foreground(lipgloss.Color("#FAFAFA")).
 				Background(lipgloss.Color("#7D56F4"))
 	infoStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#04B575"))
+			Foreground(lipgloss.AdaptiveColor{Light: "#027A4F", Dark: "#04B575"})
+	statusStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.AdaptiveColor{Light: "#666666", Dark: "#888888"})
+	cursorStyle = lipgloss.NewStyle().
+			Reverse(true)
+	selfStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.AdaptiveColor{Light: "#0069A8", Dark: "#8FD5FF"})
+	leakStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.AdaptiveColor{Light: "#C41E1E", Dark: "#FF4F4F"})
+	newStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.AdaptiveColor{Light: "#027A4F", Dark: "#04B575"})
+	dimStyle = lipgloss.NewStyle().
+			Faint(true)
+	warnStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.AdaptiveColor{Light: "#B36B00", Dark: "#FFAA00"})
 )
 
+// formatName shortens name to at most maxLen terminal cells, keeping its
+// start and end
 func formatName(name string, maxLen int) string {
-	if len(name) <= maxLen {
+	if lipgloss.Width(name) <= maxLen {
 		return name
 	}
 	if maxLen <= 3 {
-		return name[:maxLen]
+		return headCells(name, maxLen)
 	}
 	// Show beginning and end with ... in between
 	side := (maxLen - 3) / 2
-	return name[:side] + "..." + name[len(name)-side:]
+	return headCells(name, side) + "..." + tailCells(name, side)
+}
+
+// headCells is the longest start of s that fits in width terminal cells,
+// cut between characters so a multibyte or double-width one stays whole
+func headCells(s string, width int) string {
+	for i, r := range s {
+		if width -= lipgloss.Width(string(r)); width < 0 {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// tailCells is headCells for the end of s
+func tailCells(s string, width int) string {
+	for i := len(s); i > 0; {
+		r, size := utf8.DecodeLastRuneInString(s[:i])
+		if width -= lipgloss.Width(string(r)); width < 0 {
+			return s[i:]
+		}
+		i -= size
+	}
+	return s
 }
 
 func (m model) View() string {
 	if m.err != nil {
 		return fmt.Sprintf("Error: %v\n", m.err)
 	}
+	if m.showDetail {
+		return m.detailView()
+	}
+	if m.showErrors {
+		return m.errorsView()
+	}
+	if m.showReconcile {
+		return m.reconcileView()
+	}
+	if m.dashboard {
+		return m.dashboardView()
+	}
+	return m.body() + m.statusBar()
+}
 
+// body renders everything but the status bar, so it only changes when the
+// displayed data does
+func (m model) body() string {
 	// Calculate breakdown for unified memory systems
 	// Total Physical = OS Visible RAM + Hardware Reserved VRAM
 	physicalTotal := m.totalRAM + m.gpuInfo.VRAMTotal
 
 	gpuInRAM := m.gpuInfo.GTTTotal
+	usedRAM := m.smoothed(m.hist.ram, m.usedRAM)
+	vramUsed := m.smoothed(m.hist.vram, m.gpuInfo.VRAMUsed)
+	gttUsed := m.smoothed(m.hist.gtt, m.gpuInfo.GTTUsed)
+	// Used RAM includes unreclaimable slab; it gets its own Kernel line so
+	// the three add up to used. Reclaimable slab counts as cache, not used.
 	systemUsed := uint64(0)
-	if m.usedRAM > gpuInRAM {
-		systemUsed = m.usedRAM - gpuInRAM
+	if usedRAM > gpuInRAM+m.slabUnreclaim {
+		systemUsed = usedRAM - gpuInRAM - m.slabUnreclaim
 	}
 	systemUsedPercent := float64(systemUsed) / float64(m.totalRAM) * 100
 	gttOfSystemPercent := float64(gpuInRAM) / float64(m.totalRAM) * 100
 
 	s := titleStyle.Render("Memory Monitor") + "\n\n"
+	if banner := m.oomBanner(); banner != "" {
+		s += banner + "\n"
+	}
+	if len(m.gpus) > 1 {
+		s += m.gpuSummary() + "\n\n"
+	}
 	s += headerStyle.Render("Physical Memory Breakdown") + "\n"
-	s += fmt.Sprintf("Total Physical RAM: %s\n", formatBytes(physicalTotal))
-	s += fmt.Sprintf("  ├─ OS Visible:     %s (%.1f%%)\n", formatBytes(m.totalRAM), float64(m.totalRAM)/float64(physicalTotal)*100)
-	s += fmt.Sprintf("  │   ├─ System:     %s (%.1f%%)\n", formatBytes(systemUsed), systemUsedPercent)
-	s += fmt.Sprintf("  │   └─ GPU GTT:    %s (%.1f%%)\n", formatBytes(gpuInRAM), gttOfSystemPercent)
-	s += fmt.Sprintf("  └─ Hardware Res:   %s (Fixed VRAM)\n", formatBytes(m.gpuInfo.VRAMTotal))
+	breakdown := treeNode{label: "Total Physical RAM", value: formatBytes(physicalTotal), children: []treeNode{
+		{label: "OS Visible", value: fmt.Sprintf("%s (%.1f%%)", formatBytes(m.totalRAM), float64(m.totalRAM)/float64(physicalTotal)*100), children: []treeNode{
+			{label: "System", value: fmt.Sprintf("%s (%.1f%%)%s", formatBytes(systemUsed), systemUsedPercent, m.averageNote())},
+			{label: "Kernel", value: fmt.Sprintf("%s (slab, plus %s reclaimable)", formatBytes(m.slabUnreclaim), formatBytes(m.slabReclaimable))},
+			{label: "GPU GTT", value: fmt.Sprintf("%s (%.1f%%)", formatBytes(gpuInRAM), gttOfSystemPercent)},
+		}},
+		{label: "Hardware Res", value: fmt.Sprintf("%s (Fixed VRAM)", formatBytes(m.gpuInfo.VRAMTotal))},
+	}}
+	s += breakdown.render(breakdownDepth - m.treeCollapsed)
+	if m.swapTotal > 0 || len(m.zram) > 0 {
+		s += m.swapNode().render(breakdownDepth - m.treeCollapsed)
+	}
+	if m.showCommit && m.commitLimit > 0 {
+		// Only enforced with vm.overcommit_memory=2, but far above 100% the
+		// OOM killer has a lot of promises it can't keep
+		ratio := float64(m.committedAS) / float64(m.commitLimit) * 100
+		s += fmt.Sprintf("Committed:          %s of %s limit %s\n", formatBytes(m.committedAS), formatBytes(m.commitLimit),
+			m.bands.style(ratio).Render(fmt.Sprintf("(%.1f%%)", ratio)))
+	}
 
-	s += "\n" + headerStyle.Render("AMD GPU Memory Status") + "\n"
-	s += fmt.Sprintf("VRAM (Dedicated): %s / %s\n", formatBytes(m.gpuInfo.VRAMUsed), formatBytes(m.gpuInfo.VRAMTotal))
-	s += fmt.Sprintf("GTT  (Shared):    %s / %s\n", formatBytes(m.gpuInfo.GTTUsed), formatBytes(m.gpuInfo.GTTTotal))
+	if cg := m.cgroupMem; cg != nil {
+		s += "\n" + headerStyle.Render("cgroup Memory ("+filepath.Base(cg.Path)+")") + "\n"
+		if cg.Limit > 0 {
+			percent := float64(cg.Usage) / float64(cg.Limit) * 100
+			s += fmt.Sprintf("Usage:   %s / %s limit %s\n", formatBytes(cg.Usage), formatBytes(cg.Limit),
+				m.bands.style(percent).Render(fmt.Sprintf("(%.1f%%)", percent)))
+		} else {
+			s += fmt.Sprintf("Usage:   %s (no limit)\n", formatBytes(cg.Usage))
+		}
+		s += fmt.Sprintf("  anon %s | file %s | kernel %s | shmem %s\n",
+			formatBytes(cg.Anon), formatBytes(cg.File), formatBytes(cg.Kernel), formatBytes(cg.Shmem))
+	}
+
+	gpuTitle := "AMD GPU Memory Status"
+	if m.gpuInfo.Card != "" {
+		gpuTitle += fmt.Sprintf(" (%s)", m.gpuInfo.Card)
+	}
+	if m.gpuInfo.IsVF {
+		gpuTitle += " [SR-IOV virtual function]"
+	}
+	s += "\n" + headerStyle.Render(gpuTitle) + "\n"
+	s += fmt.Sprintf("VRAM (Dedicated): %s / %s%s\n", formatBytes(vramUsed), formatBytes(m.gpuInfo.VRAMTotal), m.averageNote())
+	// 1% slack, sysfs and fdinfo aren't read at the same instant
+	if sum := m.processVRAM(); sum > m.gpuInfo.VRAMUsed+m.gpuInfo.VRAMTotal/100 {
+		s += warnStyle.Render(fmt.Sprintf("[!] Processes account for %s of VRAM, more than the card reports in use: shared buffers may be counted twice or a unit misparsed",
+			formatBytes(sum))) + "\n"
+	}
+	gttPercent := 0.0
+	if m.gpuInfo.GTTTotal > 0 {
+		gttPercent = float64(gttUsed) / float64(m.gpuInfo.GTTTotal) * 100
+	}
+	s += fmt.Sprintf("GTT  (Shared):    %s / %s %s%s\n", formatBytes(gttUsed), formatBytes(m.gpuInfo.GTTTotal),
+		m.bands.style(gttPercent).Bold(true).Render(fmt.Sprintf("(%.1f%% used)", gttPercent)), m.averageNote())
+	if gttPercent >= m.bands.crit {
+		// On APUs GTT is carved from system RAM and has its own limit
+		s += warnStyle.Render("[!] GTT is nearly full: GPU allocations can stall even while VRAM looks free") + "\n"
+	}
+	s += fmt.Sprintf("PCIe:             %s\n", m.gpuInfo.PCIeLink())
+	s += fmt.Sprintf("Fan:              %s\n", m.gpuInfo.Fan())
+	s += fmt.Sprintf("Power level:      %s\n", m.gpuInfo.Power())
+	s += fmt.Sprintf("Memory bus:       %s\n", m.gpuInfo.MemBandwidth())
+
+	if m.showDomains {
+		s += "\n" + headerStyle.Render("Memory Domains") + "\n"
+		for _, d := range m.gpuInfo.Domains {
+			if d.Total > 0 {
+				s += fmt.Sprintf("  %-14s %s / %s\n", d.Name, formatBytes(d.Used), formatBytes(d.Total))
+			} else {
+				s += fmt.Sprintf("  %-14s %s\n", d.Name, formatBytes(d.Used))
+			}
+		}
+	}
+
+	s += "\n" + m.historyView()
 
 	if !m.isPrivileged {
 		s += "\n[!] Run with sudo for full process breakdown.\n"
-	} else if len(m.processes) > 0 {
-		s += "\n" + headerStyle.Render(fmt.Sprintf("Top Processes (Sorted by %s)", m.sortBy)) + "\n"
-
-		// Header row with active column highlighting
-		vramHead := "VRAM"
-		gttHead := "GTT"
-		ramHead := "RAM"
-		if m.sortBy == "VRAM" {
-			vramHead = activeHeaderStyle.Render("VRAM")
+	} else if len(m.scanned) > 0 {
+		title := fmt.Sprintf("Top Processes (Sorted by %s)", m.sortBy)
+		if m.sortLocked {
+			title = fmt.Sprintf("Top Processes (Order Locked, was %s)", m.sortBy)
+		}
+		if m.baseline != nil {
+			title += " - Change Since Baseline"
+		}
+		if collect.CgroupDir != "" {
+			title += " - cgroup " + filepath.Base(collect.CgroupDir)
 		}
-		if m.sortBy == "GTT" {
-			gttHead = activeHeaderStyle.Render("GTT")
+		if name := m.presetName(); name != "" {
+			title += " - Preset " + name
 		}
-		if m.sortBy == "RAM" {
-			ramHead = activeHeaderStyle.Render("RAM")
+		if m.rocmOnly {
+			title += " - ROCm Only"
+		}
+		if m.minMem > 0 {
+			title += fmt.Sprintf(" - Using %s or More, %d Hidden", formatBytes(m.minMem), len(m.scanned)-len(m.processes))
+		}
+		s += "\n" + headerStyle.Render(title) + "\n"
+
+		if m.noFdinfoMemory {
+			s += warnStyle.Render("[!] This kernel's DRM fdinfo has no memory keys this tool knows (drm-memory-*): per-process VRAM/GTT is unavailable, not zero") + "\n"
 		}
 
-		s += fmt.Sprintf("%-6s %-40s %-12s %-12s %-12s\n", "PID", "COMMAND", vramHead, gttHead, ramHead)
+		cols := m.visibleColumns()
+		s += m.tableHeader(cols) + "\n"
 
-		limit := 15
-		if len(m.processes) < limit {
-			limit = len(m.processes)
+		visible := m.processes[m.offset:min(m.offset+tableRows, len(m.processes))]
+		// VRAM bars are relative to the largest displayed consumer
+		var t tableCtx
+		for _, p := range visible {
+			t.maxVRAM = max(t.maxVRAM, p.VRAM)
 		}
-		for i := 0; i < limit; i++ {
-			p := m.processes[i]
-			displayName := formatName(p.Name, 40)
-			s += fmt.Sprintf("%-6d %-40s %-12s %-12s %-12s\n", p.PID, displayName, formatBytes(p.VRAM), formatBytes(p.GTT), formatBytes(p.RAM))
+
+		anyLeaking := false
+		for i, p := range visible {
+			row := m.tableRow(cols, p, t)
+			if m.offset+i == m.cursor {
+				row = cursorStyle.Render(row)
+			} else if m.leaks != nil && m.leaks.isLeaking(p) {
+				row = leakStyle.Render(row)
+				anyLeaking = true
+			} else if m.isNew(p) {
+				row = newStyle.Render(row)
+			} else if m.highlightUID >= 0 && p.UID == m.highlightUID {
+				row = selfStyle.Render(row)
+			} else if m.dimIdle && isGPUIdle(p) {
+				row = dimStyle.Render(row)
+			}
+			s += row + "\n"
+		}
+		if len(m.processes) > tableRows {
+			s += statusStyle.Render(fmt.Sprintf("rows %d-%d of %d", m.offset+1, m.offset+len(visible), len(m.processes))) + "\n"
+		}
+		if anyLeaking {
+			s += leakStyle.Render(fmt.Sprintf("Red rows: VRAM grew on each of the last %d samples, possible leak", m.leaks.window)) + "\n"
+		}
+		s += m.processStats() + "\n"
+		if slices.ContainsFunc(cols, func(c column) bool { return c.id == "shared" }) {
+			s += "SHARED: dma-buf memory also held by other processes (included in each holder's totals)\n"
+		}
+		if m.verbose {
+			s += "RAM = RSS - GTT: on unified memory, GTT buffers live in system RAM and are counted in RSS,\n" +
+				"so they are subtracted to avoid counting them twice. RSS matches what top/ps report.\n"
+		}
+	}
+
+	if m.reordering {
+		if hidden := m.hiddenColumnTitles(); len(hidden) > 0 {
+			s += "\nHidden: " + strings.Join(hidden, ", ")
 		}
+		s += "\n" + m.keyHelp(reorderKeys)
+	} else if m.naming {
+		s += "\nSave filters as: " + m.nameInput + "_\n" + m.keyHelp(namingKeys)
+	} else {
+		s += "\n" + m.keyHelp(tableKeys)
 	}
+	return s
+}
 
-	s += "\nSort: [r] RAM, [g] GTT, [v] VRAM | Quit: [q]\n"
+func (m model) statusBar() string {
+	s := ""
+	if m.remote != nil {
+		s += statusStyle.Render(m.remote.host + " | ")
+	}
+	if m.isPrivileged {
+		// Sorting is always largest first
+		sorted := "sorted by " + m.sortBy + " ↓"
+		if m.sortLocked {
+			sorted = "order locked"
+		}
+		s += statusStyle.Render(sorted + " | ")
+	}
+	if m.paused() {
+		s += statusStyle.Render("paused while unfocused | ")
+	}
+	if !m.lastUpdate.IsZero() {
+		// Grows visibly when updates stall
+		age := time.Since(m.lastUpdate)
+		updated := fmt.Sprintf("updated %.1fs ago", age.Seconds())
+		if age > 2*m.interval && !m.paused() {
+			s += warnStyle.Render(updated)
+		} else {
+			s += statusStyle.Render(updated)
+		}
+		s += statusStyle.Render(" | ")
+	}
+	s += statusStyle.Render(fmt.Sprintf("scan: %dms | interval: %s", m.scanTime.Milliseconds(), m.interval))
+	if m.interval > m.askedInterval && m.askedInterval > 0 {
+		s += " " + warnStyle.Render(fmt.Sprintf("[!] Raised from %s, scans kept taking longer", m.askedInterval))
+	}
+	if n := len(m.errLog); n > 0 {
+		s += statusStyle.Render(fmt.Sprintf(" | errors: %d [e]", n))
+	}
+	if m.scanTime > m.interval*8/10 {
+		s += " " + warnStyle.Render("[!] Scan is close to the refresh interval, consider increasing --interval")
+	}
+	s += "\n"
 	return s
 }
 
+// gpuSummary lists the VRAM usage (in GiB) of every card on one line,
+// highlighting the selected one
+func (m model) gpuSummary() string {
+	const gib = 1 << 30
+	var parts []string
+	for i, g := range m.gpus {
+		part := fmt.Sprintf("GPU%d %.*f/%.*f", i, bytePrecision, float64(g.VRAMUsed)/gib, bytePrecision, float64(g.VRAMTotal)/gib)
+		if g.IsVF {
+			part += " (VF)"
+		}
+		if i == m.selectedGPU {
+			part = activeHeaderStyle.Render(part)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "  ")
+}
+
+// compactNumbers selects formatBytes' short form, "2.1G" instead of "2.1 GiB"
+var compactNumbers bool
+
+// bytePrecision is the number of decimals formatBytes prints, see --precision
+var bytePrecision = 1
+
+// bytesWidth fits any formatBytes value, with a delta sign
+func bytesWidth() int {
+	// Room for the widest value at one decimal, e.g. "-1023.9 MiB"
+	extra := bytePrecision - 1
+	if bytePrecision == 0 {
+		extra = -2 // no decimal point either
+	}
+	if compactNumbers {
+		return 8 + extra
+	}
+	return 12 + extra
+}
+
 func formatBytes(b uint64) string {
 	const unit = 1024
 	if b < unit {
+		if compactNumbers {
+			return fmt.Sprintf("%dB", b)
+		}
 		return fmt.Sprintf("%d B", b)
 	}
+	const suffixes = "KMGTPE"
 	div, exp := uint64(unit), 0
-	for n := b / unit; n >= unit; n /= unit {
+	// Stop at the largest suffix so huge values can't index past it
+	for n := b / unit; n >= unit && exp < len(suffixes)-1; n /= unit {
 		div *= unit
 		exp++
 	}
-	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+	if compactNumbers {
+		return fmt.Sprintf("%.*f%c", bytePrecision, float64(b)/float64(div), suffixes[exp])
+	}
+	return fmt.Sprintf("%.*f %ciB", bytePrecision, float64(b)/float64(div), suffixes[exp])
+}
+
+// formatDelta renders the change from base to cur, e.g. "+123.0 MiB"
+func formatDelta(cur, base uint64) string {
+	if cur >= base {
+		return "+" + formatBytes(cur-base)
+	}
+	return "-" + formatBytes(base-cur)
+}
+
+// interactiveTerminal reports whether stdout is a terminal capable of
+// running the TUI
+func interactiveTerminal() bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
 }
 
 func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run is the whole program; failures are returned rather than fatal so
+// deferred cleanup such as stopping a --profile still happens
+func run() error {
+	interval := flag.Duration("interval", time.Second, "refresh interval")
+	watch := flag.Bool("watch", false, "print a plain frame per interval instead of the interactive TUI")
+	quiet := flag.Bool("quiet", false, "with --watch, only print a frame when memory usage changed")
+	dashboard := flag.Bool("dashboard", false, "show large gauges only, without the process table")
+	warnAt := flag.Float64("warn-threshold", 70, "usage percent at which gauges turn yellow")
+	critAt := flag.Float64("crit-threshold", 90, "usage percent at which gauges turn red")
+	oomAt := flag.Float64("oom-threshold", 5, "show an OOM-risk banner when available RAM drops below this percent (0 disables)")
+	anomalySigma := flag.Float64("anomaly-sigma", 0, "log VRAM spikes more than this many standard deviations above the rolling mean (0 disables)")
+	leakWindow := flag.Int("leak-window", 0, "highlight processes whose VRAM grew on each of the last N ticks (0 disables)")
+	logPath := flag.String("log", "", "file or FIFO to write logged events to (default stderr)")
+	outputPath := flag.String("output", "", "append --json-stream or --csv output to this file or FIFO instead of stdout")
+	exitAfter := flag.Duration("exit-after", 0, "quit after this long, e.g. 30s (0 runs until quit)")
+	verbose := flag.Bool("verbose", false, "show raw RSS next to the GTT-adjusted RAM column")
+	exe := flag.Bool("exe", false, "show the resolved executable (/proc/<pid>/exe) of each process")
+	nodes := flag.Bool("nodes", false, "show the /dev/dri node each process opened the GPU through")
+	highlightSelf := flag.Bool("highlight-self", false, "highlight processes owned by the current user")
+	flag.BoolVar(&compactNumbers, "compact-numbers", false, "print sizes in a short form, e.g. 2.1G instead of 2.1 GiB")
+	flag.IntVar(&bytePrecision, "precision", 1, "decimal places of memory sizes, 0 to 3")
+	noColor := flag.Bool("no-color", false, "disable colors and text styling (also set by NO_COLOR)")
+	theme := flag.String("theme", "auto", "color theme: auto (follow the terminal background), dark or light")
+	gpu := flag.String("gpu", "", "PCI address of the GPU to monitor, e.g. 0000:03:00.0 (see --list-gpus)")
+	listGPUs := flag.Bool("list-gpus", false, "list the detected AMD GPUs and exit")
+	openMetrics := flag.Bool("openmetrics", false, "print one snapshot in the Prometheus text format ("+metricsContentType+") and exit")
+	statusLine := flag.Bool("statusline", false, "print a single status line and exit, for tmux or i3 status bars (see --format)")
+	statusFormat := flag.String("format", defaultStatusFormat, "--statusline template; fields: {ram} {ram_total} {ram_pct} {avail} {card} {vram} {vram_total} {vram_pct} {gtt} {gtt_total} {gtt_pct}")
+	jsonStream := flag.Bool("json-stream", false, "print one JSON object per interval (JSON Lines) instead of the TUI")
+	csvOut := flag.Bool("csv", false, "print one CSV row per process and interval instead of the TUI")
+	delimiter := flag.String("delimiter", ",", `field separator for --csv, e.g. ";" or "tab"`)
+	average := flag.Int("average", 0, "show RAM/VRAM/GTT as the mean of the last N samples ([a] toggles, 0 starts with instant values)")
+	flag.StringVar(&collect.CgroupDir, "cgroup", "", "only list processes in this cgroup and its children, e.g. /sys/fs/cgroup/system.slice/foo.service")
+	sqlitePath := flag.String("sqlite", "", "append each tick's process rows to this SQLite database (needs the sqlite3 command)")
+	reportPath := flag.String("report", "", "on quit, write a plain-text session summary to this file (- for stdout)")
+	simulate := flag.Bool("simulate", false, "show generated demo data instead of reading the hardware")
+	remote := flag.String("remote", "", "monitor another machine over ssh, e.g. user@host")
+	remoteCommand := flag.String("remote-command", "mem-monitor", "mem-monitor binary to run on the --remote host")
+	setTitle := flag.Bool("title", false, "show RAM/VRAM/GTT usage in the terminal window title")
+	pauseUnfocused := flag.Bool("pause-unfocused", false, "stop refreshing while the terminal window is unfocused, on terminals that report focus")
+	probe := flag.Bool("probe", false, "print which GPUs, sysfs files and per-process data this machine exposes, then exit")
+	bench := flag.Int("bench", 0, "time the GPU stats and process scan collectors over N runs each, print percentiles and exit")
+	profile := flag.String("profile", "", "write a pprof profile of the run: cpu or mem (see --profile-file)")
+	profileFile := flag.String("profile-file", "", "where --profile writes to (default mem-monitor.<cpu|mem>.pprof)")
+	flag.Parse()
+
+	if *interval <= 0 {
+		return errors.New("--interval must be positive")
+	}
+	if bytePrecision < 0 || bytePrecision > 3 {
+		return errors.New("--precision must be between 0 and 3")
+	}
+	switch *theme {
+	case "auto":
+	case "dark":
+		lipgloss.SetHasDarkBackground(true)
+	case "light":
+		lipgloss.SetHasDarkBackground(false)
+	default:
+		return fmt.Errorf("unknown --theme %q, expected auto, dark or light", *theme)
+	}
+
+	if *noColor {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+
+	var deadline time.Time
+	if *exitAfter > 0 {
+		deadline = time.Now().Add(*exitAfter)
+	}
+	// Ctrl+C or kill ends the plain output loops like --exit-after does, so
+	// the report and sqlite sink are still finished. The TUI handles both
+	// signals itself. A second signal kills a scan that hangs.
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	context.AfterFunc(ctx, stopSignals)
+
+	if *warnAt > *critAt {
+		return errors.New("--warn-threshold must not be above --crit-threshold")
+	}
+
+	delim, err := parseDelimiter(*delimiter)
+	if err != nil {
+		return err
+	}
+
+	if *profile != "" {
+		path := *profileFile
+		if path == "" {
+			path = "mem-monitor." + *profile + ".pprof"
+		}
+		stop, err := startProfile(*profile, path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := stop(); err != nil {
+				log.Print(err)
+			}
+		}()
+	}
+
+	if *listGPUs {
+		return runListGPUs(os.Stdout)
+	}
+
+	if *simulate {
+		collectSnapshot = newSimulator().snapshot
+	}
+
+	if *remote != "" {
+		if *watch || *openMetrics || *statusLine || *bench > 0 || *probe || *jsonStream || *csvOut || !interactiveTerminal() {
+			return errors.New("--remote only drives the interactive TUI")
+		}
+	} else if !*simulate {
+		if *gpu != "" {
+			if _, err := collect.GetGPUStatsByPCI(*gpu); err != nil {
+				return err
+			}
+		}
+
+		// Everything past this point reads per-process data from /proc
+		if _, err := os.Stat("/proc/self"); err != nil {
+			return errors.New("this tool requires procfs on Linux (/proc is not mounted)")
+		}
+		if collect.CgroupDir != "" {
+			if _, err := os.Stat(filepath.Join(collect.CgroupDir, "cgroup.procs")); err != nil {
+				return fmt.Errorf("--cgroup %s is not a cgroup directory: %v", collect.CgroupDir, err)
+			}
+		}
+	}
+
+	if *probe {
+		if *simulate {
+			return errors.New("--probe looks at the real hardware, it can't be combined with --simulate")
+		}
+		return runProbe(os.Stdout)
+	}
+
+	if *bench > 0 {
+		if *simulate {
+			return errors.New("--bench times the real collectors, it can't be combined with --simulate")
+		}
+		return runBench(ctx, os.Stdout, *bench)
+	}
+
+	if *openMetrics {
+		snap, err := collectSnapshot()
+		if err == nil {
+			err = writeMetrics(os.Stdout, snap)
+		}
+		return err
+	}
+
+	if *statusLine {
+		if err := checkStatusFormat(*statusFormat); err != nil {
+			return err
+		}
+		// Status bars are narrow; "2.1G" rather than "2.1 GiB"
+		compactNumbers = true
+		// No field needs the process scan, which is most of a snapshot's cost
+		scan := collect.CollectSummary
+		if *simulate {
+			scan = collectSnapshot
+		}
+		return runStatusLine(os.Stdout, scan, *statusFormat, *gpu)
+	}
+
+	if *jsonStream || *csvOut {
+		var out io.WriteCloser = os.Stdout
+		if *outputPath != "" {
+			if out, err = openOutput(*outputPath); err != nil {
+				return err
+			}
+		}
+		if *jsonStream {
+			err = runJSONStream(ctx, out, *interval, deadline)
+		} else {
+			err = runCSV(ctx, out, *interval, deadline, delim)
+		}
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+
+	st := loadState()
 	m := model{
-		isPrivileged: os.Geteuid() == 0,
-		sortBy:       "RAM",
+		isPrivileged:   collect.CanReadForeignFdinfo(),
+		sortBy:         "RAM",
+		interval:       *interval,
+		askedInterval:  *interval,
+		hist:           newHistory(historyWindows[0], *interval),
+		columns:        normalizeColumnOrder(st.Columns),
+		columnToggles:  st.ShownColumns,
+		presets:        st.Presets,
+		preset:         -1,
+		exitAfter:      *exitAfter,
+		dashboard:      *dashboard,
+		verbose:        *verbose,
+		showNodes:      *nodes,
+		showExe:        *exe,
+		pauseUnfocused: *pauseUnfocused,
+		setTitle:       *setTitle,
+		pinnedGPU:      *gpu,
+		highlightUID:   -1,
+		averaged:       *average > 0,
+		averageSamples: defaultAverageSamples,
+		noColor:        *noColor || os.Getenv("NO_COLOR") != "",
+		bands:          severityBands{warn: *warnAt, crit: *critAt},
+		oomThreshold:   *oomAt,
 	}
 
-	p := tea.NewProgram(m)
-	if _, err := p.Run(); err != nil {
-		log.Fatal(err)
+	if *remote != "" {
+		r, err := newRemoteStream(*remote, *remoteCommand, *interval)
+		if err != nil {
+			return err
+		}
+		defer r.cmd.Process.Kill()
+		m.remote = r
+		// The remote decides what it can see; a local euid says nothing about it
+		m.isPrivileged = true
+	}
+	if *simulate {
+		// Generated data has nothing to hide from an unprivileged user
+		m.isPrivileged = true
+	}
+
+	if *average > 0 {
+		m.averageSamples = *average
+	}
+
+	if *highlightSelf {
+		m.highlightUID = int32(os.Getuid())
+		// Running under sudo, "self" is whoever invoked it rather than root
+		if uid, err := strconv.Atoi(os.Getenv("SUDO_UID")); err == nil {
+			m.highlightUID = int32(uid)
+		}
+	}
+
+	eventLog := log.New(os.Stderr, "", log.LstdFlags)
+	if *logPath != "" {
+		f, err := openOutput(*logPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		eventLog.SetOutput(f)
+	}
+	// bubbletea can't drive dumb terminals or pipes, fall back to plain frames
+	plain := *watch || !interactiveTerminal()
+	if *anomalySigma > 0 {
+		// Writing to stderr would scribble over the TUI, so there spikes
+		// go to the [e] panel unless --log says where to put them
+		spikeLog := eventLog
+		if *logPath == "" && !plain {
+			spikeLog = nil
+		}
+		m.spikes = newSpikeDetector(spikeLog, *anomalySigma)
+	}
+	if *leakWindow > 0 {
+		// Leaks are only logged when asked to, they're highlighted regardless
+		var leakLog *log.Logger
+		if *logPath != "" {
+			leakLog = eventLog
+		}
+		m.leaks = newLeakTracker(*leakWindow, leakLog)
+	}
+	if *reportPath != "" {
+		m.report = newSessionReport(m.bands)
+	}
+	if *sqlitePath != "" {
+		sink, err := newSQLiteSink(*sqlitePath)
+		if err != nil {
+			return err
+		}
+		m.sqlite = sink
+	}
+
+	if plain {
+		err = runWatch(ctx, os.Stdout, m, *quiet, deadline)
+	} else {
+		var opts []tea.ProgramOption
+		if m.dashboard {
+			opts = append(opts, tea.WithAltScreen())
+		}
+		if m.pauseUnfocused {
+			opts = append(opts, tea.WithReportFocus())
+		}
+		_, err = tea.NewProgram(m, opts...).Run()
+	}
+
+	if m.sqlite != nil {
+		if err := m.sqlite.close(); err != nil {
+			log.Print(err)
+		}
+	}
+	// The report still covers a session that ended in an error
+	if m.report != nil {
+		if err := writeReport(*reportPath, m.report); err != nil {
+			log.Print(err)
+		}
+	}
+	return err
+}
+
+func writeReport(path string, r *sessionReport) error {
+	if path == "-" {
+		return r.write(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := r.write(f); err != nil {
+		f.Close()
+		return err
 	}
+	return f.Close()
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		in   uint64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1 << 10, "1.0 KiB"},
+		{1<<20 - 1, "1024.0 KiB"},
+		{1 << 20, "1.0 MiB"},
+		{1<<30 - 1, "1024.0 MiB"},
+		{1 << 30, "1.0 GiB"},
+		{1<<40 - 1, "1024.0 GiB"},
+		{1 << 40, "1.0 TiB"},
+		{1<<50 - 1, "1024.0 TiB"},
+		{1 << 50, "1.0 PiB"},
+		{1<<60 - 1, "1024.0 PiB"},
+		{1 << 60, "1.0 EiB"},
+		// Past the largest suffix, which must not index out of range
+		{math.MaxUint64, "16.0 EiB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.in); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatBytesCompact(t *testing.T) {
+	compactNumbers = true
+	defer func() { compactNumbers = false }()
+
+	tests := []struct {
+		in   uint64
+		want string
+	}{
+		{1023, "1023B"},
+		{1 << 10, "1.0K"},
+		{1 << 60, "1.0E"},
+		{math.MaxUint64, "16.0E"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.in); got != tt.want {
+			t.Errorf("compact formatBytes(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatName(t *testing.T) {
+	tests := []struct {
+		name   string
+		maxLen int
+		want   string
+	}{
+		{"python3", 10, "python3"},
+		{"abcdefghij", 7, "ab...ij"},
+		{"abcdefghij", 3, "abc"},
+		// Double-width characters are cut whole and count as two cells
+		{"データベース", 12, "データベース"},
+		{"データベース", 7, "デ...ス"},
+		{"aデータb", 6, "a...b"},
+		{"データ", 3, "デ"},
+		{"/opt/x/データ/bin/データ", 16, "/opt/x...データ"},
+	}
+	for _, tt := range tests {
+		got := formatName(tt.name, tt.maxLen)
+		if got != tt.want {
+			t.Errorf("formatName(%q, %d) = %q, want %q", tt.name, tt.maxLen, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("formatName(%q, %d) = %q, not valid UTF-8", tt.name, tt.maxLen, got)
+		}
+	}
+}
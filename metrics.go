@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"mem-monitor/collect"
+)
+
+// metricsContentType is the Prometheus text exposition format we write
+const metricsContentType = "text/plain; version=0.0.4"
+
+type metricSample struct {
+	labels [][2]string // name, value pairs
+	value  uint64
+}
+
+// metricDef is one exported gauge. Keeping the definitions in one table means
+// every exporter emits the same names, help text and labels.
+type metricDef struct {
+	name    string
+	help    string
+	samples func(collect.Snapshot) []metricSample
+}
+
+var metricDefs = []metricDef{
+	{"mem_monitor_ram_total_bytes", "OS visible system RAM.", func(s collect.Snapshot) []metricSample {
+		return []metricSample{{value: s.TotalRAM}}
+	}},
+	{"mem_monitor_ram_used_bytes", "System RAM in use, including GTT.", func(s collect.Snapshot) []metricSample {
+		return []metricSample{{value: s.UsedRAM}}
+	}},
+	{"mem_monitor_gpu_vram_total_bytes", "Dedicated VRAM of the card.", gpuSamples(func(g collect.GPUInfo) uint64 { return g.VRAMTotal })},
+	{"mem_monitor_gpu_vram_used_bytes", "Dedicated VRAM in use.", gpuSamples(func(g collect.GPUInfo) uint64 { return g.VRAMUsed })},
+	{"mem_monitor_gpu_gtt_total_bytes", "GTT (system RAM mapped for the GPU) available to the card.", gpuSamples(func(g collect.GPUInfo) uint64 { return g.GTTTotal })},
+	{"mem_monitor_gpu_gtt_used_bytes", "GTT in use.", gpuSamples(func(g collect.GPUInfo) uint64 { return g.GTTUsed })},
+	{"mem_monitor_process_vram_bytes", "VRAM used by a process.", processSamples(func(p collect.ProcessGPUInfo) uint64 { return p.VRAM })},
+	{"mem_monitor_process_gtt_bytes", "GTT used by a process.", processSamples(func(p collect.ProcessGPUInfo) uint64 { return p.GTT })},
+	{"mem_monitor_process_ram_bytes", "RAM used by a process, excluding its GTT.", processSamples(func(p collect.ProcessGPUInfo) uint64 { return p.RAM })},
+}
+
+func gpuSamples(val func(collect.GPUInfo) uint64) func(collect.Snapshot) []metricSample {
+	return func(s collect.Snapshot) []metricSample {
+		var out []metricSample
+		for _, g := range s.GPUs {
+			out = append(out, metricSample{labels: [][2]string{{"card", g.Card}}, value: val(g)})
+		}
+		return out
+	}
+}
+
+func processSamples(val func(collect.ProcessGPUInfo) uint64) func(collect.Snapshot) []metricSample {
+	return func(s collect.Snapshot) []metricSample {
+		var out []metricSample
+		for _, p := range s.Processes {
+			out = append(out, metricSample{
+				labels: [][2]string{
+					{"pid", strconv.Itoa(int(p.PID))},
+					{"name", p.Name},
+					{"card", p.Card},
+				},
+				value: val(p),
+			})
+		}
+		return out
+	}
+}
+
+// writeMetrics writes a snapshot in the Prometheus text exposition format
+func writeMetrics(w io.Writer, snap collect.Snapshot) error {
+	var b strings.Builder
+	for _, def := range metricDefs {
+		fmt.Fprintf(&b, "# HELP %s %s\n", def.name, def.help)
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", def.name)
+		for _, sample := range def.samples(snap) {
+			b.WriteString(def.name)
+			if len(sample.labels) > 0 {
+				b.WriteByte('{')
+				for i, l := range sample.labels {
+					if i > 0 {
+						b.WriteByte(',')
+					}
+					fmt.Fprintf(&b, "%s=\"%s\"", l[0], escapeLabelValue(l[1]))
+				}
+				b.WriteByte('}')
+			}
+			fmt.Fprintf(&b, " %d\n", sample.value)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
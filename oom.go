@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"mem-monitor/collect"
+)
+
+// oomCandidates is how many likely OOM-kill victims the banner names
+const oomCandidates = 3
+
+var oomBannerStyle = lipgloss.NewStyle().
+	Bold(true).
+	Foreground(lipgloss.Color("#FAFAFA")).
+	Background(lipgloss.Color("#C41E1E")).
+	Padding(0, 1)
+
+// oomRisk reports whether available memory is below --oom-threshold percent
+// of RAM
+func (m model) oomRisk() bool {
+	return m.oomThreshold > 0 && m.totalRAM > 0 &&
+		float64(m.availRAM)/float64(m.totalRAM)*100 < m.oomThreshold
+}
+
+// oomBanner warns that the OOM killer may strike soon and names the
+// processes it would likely pick: the kernel's oom_score decides where it
+// could be read, RSS otherwise. Empty while there's enough memory.
+func (m model) oomBanner() string {
+	if !m.oomRisk() {
+		return ""
+	}
+
+	var procs []collect.ProcessGPUInfo
+	for _, p := range m.scanned {
+		if p.RSS > 0 { // a process's first row only
+			procs = append(procs, p)
+		}
+	}
+	sort.SliceStable(procs, func(i, j int) bool {
+		if procs[i].OOMScore != procs[j].OOMScore {
+			return procs[i].OOMScore > procs[j].OOMScore
+		}
+		return procs[i].RSS > procs[j].RSS
+	})
+
+	var names []string
+	for _, p := range procs[:min(len(procs), oomCandidates)] {
+		name := fmt.Sprintf("%s (pid %d, %s RSS", formatName(commandBase(p.Name), 20), p.PID, formatBytes(p.RSS))
+		if p.OOMScore > 0 {
+			name += fmt.Sprintf(", oom_score %d", p.OOMScore)
+		}
+		names = append(names, name+")")
+	}
+
+	s := fmt.Sprintf("OOM RISK: only %s of RAM available (%.1f%%)", formatBytes(m.availRAM),
+		float64(m.availRAM)/float64(m.totalRAM)*100)
+	if len(names) > 0 {
+		s += ". Likely kill candidates: " + strings.Join(names, ", ")
+	}
+	return oomBannerStyle.Render(s) + "\n"
+}
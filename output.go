@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"mem-monitor/collect"
+)
+
+// runJSONStream writes one snapshot per interval as a JSON line
+func runJSONStream(ctx context.Context, out io.Writer, interval time.Duration, deadline time.Time) error {
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+
+	for running(ctx, deadline) {
+		start := time.Now()
+		snap, err := collectSnapshot()
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(snap); err != nil {
+			return err
+		}
+		// Flush every line so consumers tailing the stream see it immediately
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		sleepUntil(ctx, start.Add(interval))
+	}
+	return nil
+}
+
+// runCSV writes a header and then one row per process row and interval,
+// with the same fields as --sqlite
+func runCSV(ctx context.Context, out io.Writer, interval time.Duration, deadline time.Time, delimiter rune) error {
+	w := csv.NewWriter(out)
+	w.Comma = delimiter
+	if err := w.Write([]string{"ts", "pid", "name", "card", "vram", "gtt", "ram"}); err != nil {
+		return err
+	}
+
+	for running(ctx, deadline) {
+		start := time.Now()
+		snap, err := collectSnapshot()
+		if err != nil {
+			return err
+		}
+		ts := snap.Timestamp.UTC().Format(time.RFC3339Nano)
+		for _, p := range snap.Processes {
+			err := w.Write([]string{ts, strconv.Itoa(int(p.PID)), p.Name, p.Card,
+				strconv.FormatUint(p.VRAM, 10), strconv.FormatUint(p.GTT, 10), strconv.FormatUint(p.RAM, 10)})
+			if err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+
+		sleepUntil(ctx, start.Add(interval))
+	}
+	return nil
+}
+
+// parseDelimiter accepts a single character, or "tab" / \t since a literal
+// tab is awkward to pass on a command line
+func parseDelimiter(s string) (rune, error) {
+	switch s {
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, fmt.Errorf("--delimiter must be a single character other than a quote or newline, got %q", s)
+	}
+	return r, nil
+}
+
+// runWatch prints a plain rendered frame per interval. With quiet set, a
+// frame is only printed when the memory figures changed.
+func runWatch(ctx context.Context, out io.Writer, m model, quiet bool, deadline time.Time) error {
+	var last []string
+	for running(ctx, deadline) {
+		start := time.Now()
+		next, _ := m.Update(gatherCmd())
+		m = next.(model)
+		if m.err != nil {
+			return m.err
+		}
+
+		state := m.memoryState()
+		if !quiet || last == nil || !slices.Equal(state, last) {
+			if _, err := fmt.Fprintln(out, m.body()+m.statusBar()); err != nil {
+				return err
+			}
+			last = state
+		}
+
+		sleepUntil(ctx, start.Add(m.interval))
+	}
+	return nil
+}
+
+// memoryState is what --quiet compares between frames: the host's memory
+// and swap, each card's VRAM and GTT, and each listed process's VRAM, GTT
+// and RAM, as precise as they're shown. Scan times, CPU and rates change
+// on almost every tick, so a rendered frame would hardly ever repeat.
+func (m model) memoryState() []string {
+	state := []string{strconv.Itoa(len(m.processes))}
+	for _, v := range []uint64{m.usedRAM, m.availRAM, m.swapUsed, m.slabReclaimable, m.slabUnreclaim, m.committedAS} {
+		state = append(state, formatBytes(v))
+	}
+	for _, g := range m.gpus {
+		state = append(state, formatBytes(g.VRAMUsed), formatBytes(g.GTTUsed))
+	}
+	for _, p := range m.processes {
+		state = append(state, strconv.Itoa(int(p.PID)), formatBytes(p.VRAM), formatBytes(p.GTT), formatBytes(p.RAM))
+	}
+	return state
+}
+
+// running reports whether an output loop should go on. Loops stop when
+// ctx is cancelled by a signal, or once deadline passes; a zero deadline
+// never passes.
+func running(ctx context.Context, deadline time.Time) bool {
+	return ctx.Err() == nil && (deadline.IsZero() || time.Now().Before(deadline))
+}
+
+// sleepUntil waits for t, or less if ctx is cancelled meanwhile
+func sleepUntil(ctx context.Context, t time.Time) {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// runListGPUs prints every amdgpu card the tool can see
+func runListGPUs(out io.Writer) error {
+	gpus, err := collect.GetAllGPUStats()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INDEX\tCARD\tPCI\tMODEL\tVRAM")
+	for i, g := range gpus {
+		model := g.Model
+		if g.IsVF {
+			model += " [SR-IOV VF]"
+		}
+		fmt.Fprintf(w, "GPU%d\t%s\t%s\t%s\t%s\n", i, g.Card, g.PCI, model, formatBytes(g.VRAMTotal))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"mem-monitor/collect"
+)
+
+func TestMemoryStateIgnoresCPUAndTiming(t *testing.T) {
+	a := model{usedRAM: 4 << 30, gpus: []collect.GPUInfo{{VRAMUsed: 1 << 30}},
+		processes: []collect.ProcessGPUInfo{{PID: 10, VRAM: 512 << 20, CPU: 3}}}
+	b := a
+	b.processes = []collect.ProcessGPUInfo{{PID: 10, VRAM: 512 << 20, CPU: 97}}
+	b.scanTime = 40 * time.Millisecond
+	b.usedRAM += 1 << 10 // below what's shown
+	if !slices.Equal(a.memoryState(), b.memoryState()) {
+		t.Errorf("CPU, scan time or an unshown byte count changed the state: %q vs %q", a.memoryState(), b.memoryState())
+	}
+
+	tests := []struct {
+		name   string
+		change func(m *model)
+	}{
+		{"used RAM", func(m *model) { m.usedRAM += 1 << 30 }},
+		{"card VRAM", func(m *model) { m.gpus = []collect.GPUInfo{{VRAMUsed: 2 << 30}} }},
+		{"process VRAM", func(m *model) { m.processes = []collect.ProcessGPUInfo{{PID: 10, VRAM: 1 << 30}} }},
+		{"process replaced", func(m *model) { m.processes = []collect.ProcessGPUInfo{{PID: 11, VRAM: 512 << 20}} }},
+		{"process gone", func(m *model) { m.processes = nil }},
+	}
+	for _, tt := range tests {
+		c := a
+		tt.change(&c)
+		if slices.Equal(a.memoryState(), c.memoryState()) {
+			t.Errorf("%s: state unchanged", tt.name)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// filterPreset is a named combination of the process filters, saved with
+// [F] and recalled by cycling with [f]
+type filterPreset struct {
+	Name     string `json:"name"`
+	MinMem   uint64 `json:"min_mem"`
+	ROCmOnly bool   `json:"rocm_only"`
+}
+
+// nextPreset applies the preset after the current one; past the last, the
+// filters are cleared again
+func (m *model) nextPreset() {
+	if len(m.presets) == 0 {
+		return
+	}
+	m.preset++
+	if m.preset >= len(m.presets) {
+		m.preset = -1
+		m.minMem, m.rocmOnly = 0, false
+	} else {
+		p := m.presets[m.preset]
+		m.minMem, m.rocmOnly = p.MinMem, p.ROCmOnly
+	}
+	m.applyFilters()
+}
+
+// presetName is the active preset, if the filters still match it
+func (m model) presetName() string {
+	if m.preset < 0 || m.preset >= len(m.presets) {
+		return ""
+	}
+	p := m.presets[m.preset]
+	if p.MinMem != m.minMem || p.ROCmOnly != m.rocmOnly {
+		return ""
+	}
+	return p.Name
+}
+
+// updateNaming handles keys while typing the name of a preset to save
+func (m model) updateNaming(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.naming = false
+	case tea.KeyEnter:
+		m.naming = false
+		if name := strings.TrimSpace(m.nameInput); name != "" {
+			m.savePreset(filterPreset{Name: name, MinMem: m.minMem, ROCmOnly: m.rocmOnly})
+		}
+	case tea.KeyBackspace:
+		if r := []rune(m.nameInput); len(r) > 0 {
+			m.nameInput = string(r[:len(r)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.nameInput += string(msg.Runes)
+	}
+	return m, nil
+}
+
+// savePreset adds p, replacing a preset of the same name, and persists the
+// list
+func (m *model) savePreset(p filterPreset) {
+	m.presets = append([]filterPreset(nil), m.presets...)
+	m.preset = len(m.presets)
+	for i, old := range m.presets {
+		if old.Name == p.Name {
+			m.preset = i
+		}
+	}
+	if m.preset == len(m.presets) {
+		m.presets = append(m.presets, p)
+	} else {
+		m.presets[m.preset] = p
+	}
+
+	st := loadState()
+	st.Presets = m.presets
+	saveState(st) // best effort, the preset still works this session
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"mem-monitor/collect"
+)
+
+// probeSysfsFiles are the per-card files the GPU section reads; a card
+// missing some of them shows those numbers as n/a
+var probeSysfsFiles = []string{
+	"mem_info_vram_total", "mem_info_vram_used",
+	"mem_info_vis_vram_total", "mem_info_vis_vram_used",
+	"mem_info_gtt_total", "mem_info_gtt_used",
+	"mem_busy_percent", "current_link_speed", "power_dpm_force_performance_level",
+}
+
+// pciVendors names the PCI vendor ids of GPUs the scan may come across
+var pciVendors = map[string]string{"1002": "AMD", "8086": "Intel", "10DE": "NVIDIA"}
+
+// runProbe prints what this machine lets the tool see, so users and bug
+// reports can tell up front which features will work
+func runProbe(out io.Writer) error {
+	line := func(label, format string, args ...any) {
+		fmt.Fprintf(out, "%-24s %s\n", label+":", fmt.Sprintf(format, args...))
+	}
+
+	kernel := "unknown"
+	if data, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		kernel = strings.TrimSpace(string(data))
+	}
+	line("Kernel", "%s", kernel)
+	if collect.CanReadForeignFdinfo() {
+		line("Privileged", "yes, other users' processes can be scanned")
+	} else {
+		line("Privileged", "no, only your own processes are scanned (run with sudo for all)")
+	}
+	if dir, limitFile := collect.MonitoredCgroup(); dir != "" {
+		version := "v2"
+		if limitFile != "memory.max" {
+			version = "v1"
+		}
+		line("Memory cgroup", "%s (cgroup %s)", dir, version)
+	}
+
+	cards := collect.CardDevices()
+	fmt.Fprintln(out)
+	if len(cards) == 0 {
+		line("GPUs", "none in /sys/class/drm")
+	}
+	monitored := collect.AMDDevices()
+	for _, deviceDir := range cards {
+		uevent := collect.ReadUevent(deviceDir)
+		vendor, _, _ := strings.Cut(uevent["PCI_ID"], ":")
+		name := pciVendors[strings.ToUpper(vendor)]
+		switch {
+		case name != "":
+		case vendor != "":
+			name = "vendor " + vendor
+		default:
+			name = "unknown vendor" // not a PCI device
+		}
+		name += " " + collect.GPUModel(deviceDir)
+		card := filepath.Base(filepath.Dir(deviceDir))
+		line(card, "%s (%s, driver %s)", name, collect.PCIAddress(deviceDir), driverName(uevent))
+
+		switch {
+		case slices.Contains(monitored, deviceDir):
+			var have, missing []string
+			for _, f := range probeSysfsFiles {
+				if _, err := os.Stat(filepath.Join(deviceDir, f)); err == nil {
+					have = append(have, f)
+				} else {
+					missing = append(missing, f)
+				}
+			}
+			line("  sysfs", "%s", strings.Join(have, " "))
+			if len(missing) > 0 {
+				line("  missing", "%s", strings.Join(missing, " "))
+			}
+			if !slices.Contains(have, "mem_info_vram_total") {
+				line("  card memory", "read through the AMDGPU_INFO ioctl on %s", collect.RenderNode(deviceDir))
+			}
+		case uevent["DRIVER"] == "amdgpu":
+			line("  card memory", "not monitored, neither sysfs nor the ioctl report any VRAM")
+		case uevent["DRIVER"] == "xe":
+			line("  card memory", "not monitored, its processes are listed with their fdinfo memory")
+		default:
+			line("  card memory", "not monitored, only amdgpu cards are")
+		}
+	}
+
+	procs, noMemoryKeys, err := collect.GetProcessBreakdown()
+	if err != nil {
+		return err
+	}
+	clients := map[int32]bool{}
+	for _, p := range procs {
+		if p.Card != "" {
+			clients[p.PID] = true
+		}
+	}
+	fmt.Fprintln(out)
+	switch {
+	case noMemoryKeys:
+		line("Per-process GPU memory", "unavailable, GPU fds have no drm-memory-* keys in their fdinfo (kernel too old?)")
+	case len(clients) > 0:
+		line("Per-process GPU memory", "available, %d processes using a GPU", len(clients))
+	default:
+		line("Per-process GPU memory", "unknown, no visible process has a GPU open")
+	}
+	return nil
+}
+
+// driverName is the kernel driver bound to a device, "none" when unbound
+func driverName(uevent map[string]string) string {
+	if d := uevent["DRIVER"]; d != "" {
+		return d
+	}
+	return "none"
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// startProfile begins a pprof profile of the whole run, for looking into
+// the tool's own overhead. kind is "cpu" or "mem"; the returned stop writes
+// the profile out and must be called before exiting.
+func startProfile(kind, path string) (stop func() error, err error) {
+	if kind != "cpu" && kind != "mem" {
+		return nil, fmt.Errorf("unknown --profile %q, expected cpu or mem", kind)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if kind == "cpu" {
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return func() error {
+			pprof.StopCPUProfile()
+			return f.Close()
+		}, nil
+	}
+
+	return func() error {
+		runtime.GC() // up-to-date statistics of what's still live
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}, nil
+}
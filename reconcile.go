@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"mem-monitor/collect"
+)
+
+// reconcileView is the [R] screen: for every pool, what the kernel reports
+// in use next to what the listed processes add up to, and the difference
+func (m model) reconcileView() string {
+	var vram, gtt, rss uint64
+	for _, p := range m.scanned {
+		if p.Card == m.gpuInfo.Card {
+			vram += p.VRAM
+			gtt += p.GTT
+		}
+		rss += p.RSS // only set on a process's first row
+	}
+
+	title := "Where Did My Memory Go?"
+	if m.gpuInfo.Card != "" {
+		title += fmt.Sprintf(" (%s)", m.gpuInfo.Card)
+	}
+	s := titleStyle.Render(title) + "\n\n"
+
+	gpuPool := func(name string, used, total, procs uint64) string {
+		s := headerStyle.Render(name) + "\n"
+		s += reconcileLine("In use (card)", used, total)
+		s += reconcileLine("Listed processes", procs, total)
+		s += unaccountedLine("Unaccounted", used, procs, total)
+		return s
+	}
+	s += gpuPool("VRAM", m.gpuInfo.VRAMUsed, m.gpuInfo.VRAMTotal, vram)
+	s += statusStyle.Render("  Unaccounted VRAM is usually the kernel's and firmware's own, or\n"+
+		"  processes whose fdinfo can't be read") + "\n\n"
+	s += gpuPool("GTT", m.gpuInfo.GTTUsed, m.gpuInfo.GTTTotal, gtt)
+	s += "\n"
+
+	s += headerStyle.Render("System RAM") + "\n"
+	cache := uint64(0)
+	if m.totalRAM > m.usedRAM+m.freeRAM {
+		cache = m.totalRAM - m.usedRAM - m.freeRAM
+	}
+	s += reconcileLine("Free", m.freeRAM, m.totalRAM)
+	s += reconcileLine("Cache, buffers, reclaimable slab", cache, m.totalRAM)
+	s += reconcileLine("In use", m.usedRAM, m.totalRAM)
+	s += reconcileLine("  Listed processes (RSS)", rss, m.totalRAM)
+	s += reconcileLine("  Kernel slab", m.slabUnreclaim, m.totalRAM)
+	s += unaccountedLine("  Unaccounted", m.usedRAM, rss+m.slabUnreclaim, m.totalRAM)
+	s += statusStyle.Render("  Unaccounted RAM covers processes below the listing threshold, page\n"+
+		"  tables, kernel stacks and GPU buffers no process maps") + "\n"
+
+	if m.minMem > 0 || m.rocmOnly || collect.CgroupDir != "" {
+		s += "\n" + statusStyle.Render("Sums cover every scanned process, regardless of the table's filters")
+		if collect.CgroupDir != "" {
+			s += statusStyle.Render(", but only those in the --cgroup")
+		}
+		s += "\n"
+	}
+	s += "\n" + m.keyHelp(reconcileKeys)
+	return s
+}
+
+func reconcileLine(label string, val, total uint64) string {
+	percent := ""
+	if total > 0 {
+		percent = fmt.Sprintf("(%.1f%%)", float64(val)/float64(total)*100)
+	}
+	return fmt.Sprintf("  %-34s %*s %s\n", label, bytesWidth(), formatBytes(val), percent)
+}
+
+// unaccountedLine is what's used beyond the parts, or by how much the
+// parts overshoot, which means something is counted twice
+func unaccountedLine(label string, used, parts, total uint64) string {
+	if parts > used {
+		return fmt.Sprintf("  %-34s %*s %s\n", label, bytesWidth(), "none",
+			warnStyle.Render("processes exceed it by "+formatBytes(parts-used)))
+	}
+	return reconcileLine(label, used-parts, total)
+}
+
+// updateReconcile handles keys while the reconciliation screen is open
+func (m model) updateReconcile(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "R", "esc":
+		m.showReconcile = false
+	}
+	return m, nil
+}
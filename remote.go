@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"mem-monitor/collect"
+)
+
+// remoteStream feeds the TUI from mem-monitor running --json-stream on
+// another machine over ssh, so the server needs no terminal of its own
+type remoteStream struct {
+	host   string
+	cmd    *exec.Cmd
+	lines  *bufio.Scanner
+	stderr bytes.Buffer
+}
+
+// newRemoteStream starts the remote side. --cgroup is passed on, since the
+// cgroup named is one on the remote host.
+func newRemoteStream(host, command string, interval time.Duration) (*remoteStream, error) {
+	r := &remoteStream{host: host}
+	remoteCmd := fmt.Sprintf("%s --json-stream --interval %s", command, interval)
+	if collect.CgroupDir != "" {
+		remoteCmd += " --cgroup " + shellQuote(collect.CgroupDir)
+	}
+	r.cmd = exec.Command("ssh", host, remoteCmd)
+	r.cmd.Stderr = &r.stderr
+
+	out, err := r.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting ssh: %w", err)
+	}
+	r.lines = bufio.NewScanner(out)
+	r.lines.Buffer(make([]byte, 0, 64*1024), 16*1024*1024) // snapshots with many processes are long lines
+	return r, nil
+}
+
+// next blocks until the remote sends its next snapshot
+func (r *remoteStream) next() tea.Msg {
+	if !r.lines.Scan() {
+		err := r.cmd.Wait()
+		msg := strings.TrimSpace(r.stderr.String())
+		if msg == "" && err != nil {
+			msg = err.Error()
+		}
+		return tickMsg{err: fmt.Errorf("stream from %s ended: %s", r.host, msg)}
+	}
+
+	var snap collect.Snapshot
+	if err := json.Unmarshal(r.lines.Bytes(), &snap); err != nil {
+		return tickMsg{err: fmt.Errorf("bad snapshot from %s: %w", r.host, err)}
+	}
+	// Age the data by local arrival rather than the remote clock, which
+	// may be skewed
+	snap.Timestamp = time.Now()
+	// Device paths are the remote's; read here they'd show this machine's
+	// sysfs as the remote card's
+	for i := range snap.GPUs {
+		snap.GPUs[i].DeviceDir = ""
+	}
+	return tickMsg{Snapshot: snap}
+}
+
+// shellQuote quotes s for the remote shell ssh runs the command with
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"mem-monitor/collect"
+)
+
+// reportTopN is how many processes the session report lists
+const reportTopN = 10
+
+// sessionReport accumulates a run's peaks and threshold breaches so they
+// can be summarized in plain text on exit
+type sessionReport struct {
+	bands    severityBands
+	start    time.Time
+	last     time.Time
+	samples  int
+	peaks    [3]reportPeak // RAM, VRAM, GTT
+	procs    map[leakKey]*reportProc
+	breaches [3]reportBreach
+}
+
+type reportPeak struct {
+	used, total uint64
+	at          time.Time
+}
+
+type reportProc struct {
+	pid        int32
+	name       string
+	card       string
+	vram, gtt  uint64
+	ram        uint64
+	vramPeakAt time.Time
+}
+
+// reportBreach counts the samples a resource spent in each severity band
+type reportBreach struct {
+	warn, crit  int
+	firstCritAt time.Time
+}
+
+var reportResources = [3]string{"RAM", "VRAM", "GTT"}
+
+func newSessionReport(bands severityBands) *sessionReport {
+	return &sessionReport{bands: bands, procs: map[leakKey]*reportProc{}}
+}
+
+func (r *sessionReport) observe(at time.Time, totalRAM, usedRAM uint64, g collect.GPUInfo, procs []collect.ProcessGPUInfo) {
+	if r.samples == 0 {
+		r.start = at
+	}
+	r.last = at
+	r.samples++
+
+	usage := [3][2]uint64{{usedRAM, totalRAM}, {g.VRAMUsed, g.VRAMTotal}, {g.GTTUsed, g.GTTTotal}}
+	for i, u := range usage {
+		if u[0] > r.peaks[i].used || r.peaks[i].at.IsZero() {
+			r.peaks[i] = reportPeak{used: u[0], total: u[1], at: at}
+		}
+		if u[1] == 0 {
+			continue
+		}
+		percent := float64(u[0]) / float64(u[1]) * 100
+		b := &r.breaches[i]
+		switch {
+		case percent >= r.bands.crit:
+			if b.crit == 0 {
+				b.firstCritAt = at
+			}
+			b.crit++
+		case percent >= r.bands.warn:
+			b.warn++
+		}
+	}
+
+	for _, p := range procs {
+		k := leakKeyOf(p)
+		rp := r.procs[k]
+		if rp == nil {
+			rp = &reportProc{pid: p.PID, name: p.Name, card: p.Card}
+			r.procs[k] = rp
+		}
+		if p.VRAM > rp.vram {
+			rp.vram = p.VRAM
+			rp.vramPeakAt = at
+		}
+		rp.gtt = max(rp.gtt, p.GTT)
+		rp.ram = max(rp.ram, p.RAM)
+	}
+}
+
+// write formats the report; peaks are per resource and per process, so the
+// columns of one process needn't come from the same sample
+func (r *sessionReport) write(out io.Writer) error {
+	if r.samples == 0 {
+		_, err := fmt.Fprintln(out, "mem-monitor session report: no samples were collected")
+		return err
+	}
+
+	fmt.Fprintln(out, "mem-monitor session report")
+	fmt.Fprintf(out, "Started:  %s\n", r.start.Format(time.DateTime))
+	fmt.Fprintf(out, "Duration: %s (%d samples)\n\n", r.last.Sub(r.start).Round(time.Second), r.samples)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PEAK\tUSED\tOF\tAT")
+	for i, p := range r.peaks {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", reportResources[i], formatBytes(p.used), formatBytes(p.total), p.at.Format(time.TimeOnly))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "\nThreshold breaches (warn %.0f%%, crit %.0f%%):\n", r.bands.warn, r.bands.crit)
+	breached := false
+	for i, b := range r.breaches {
+		if b.warn == 0 && b.crit == 0 {
+			continue
+		}
+		breached = true
+		line := fmt.Sprintf("  %s: %d samples at warn, %d at crit", reportResources[i], b.warn, b.crit)
+		if b.crit > 0 {
+			line += ", first crit at " + b.firstCritAt.Format(time.TimeOnly)
+		}
+		fmt.Fprintln(out, line)
+	}
+	if !breached {
+		fmt.Fprintln(out, "  none")
+	}
+
+	procs := make([]*reportProc, 0, len(r.procs))
+	for _, p := range r.procs {
+		procs = append(procs, p)
+	}
+	sort.Slice(procs, func(i, j int) bool {
+		if procs[i].vram != procs[j].vram {
+			return procs[i].vram > procs[j].vram
+		}
+		return procs[i].ram > procs[j].ram
+	})
+	procs = procs[:min(len(procs), reportTopN)]
+
+	fmt.Fprintf(out, "\nTop %d processes by peak VRAM:\n", len(procs))
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PID\tCOMMAND\tCARD\tVRAM\tGTT\tRAM\tVRAM PEAK AT")
+	for _, p := range procs {
+		at := "-"
+		if !p.vramPeakAt.IsZero() {
+			at = p.vramPeakAt.Format(time.TimeOnly)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", p.pid, formatName(p.name, 40), p.card,
+			formatBytes(p.vram), formatBytes(p.gtt), formatBytes(p.ram), at)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"mem-monitor/collect"
+)
+
+// collectSnapshot is where every mode gets its data from: Collect, or the
+// simulator with --simulate
+var collectSnapshot = collect.Collect
+
+// simulator produces deterministic, slowly varying data for demos and
+// screenshots, and for trying the UI without AMD hardware. Values depend
+// only on the sample count, so every run shows the same sequence.
+type simulator struct {
+	n int
+}
+
+type simProcess struct {
+	pid        int32
+	name       string
+	vram, gtt  uint64 // baseline, varied over time
+	ram        uint64
+	cpu        float64
+	decode     float64
+	rocm       bool
+	createTime int64
+	exe        string
+}
+
+// An APU like the 7840U, where most GPU memory is GTT carved from RAM
+var simProcesses = []simProcess{
+	{1432, "/usr/lib/xorg/Xorg vt2 -displayfd 3", 60 << 20, 120 << 20, 180 << 20, 3, 0, false, 1760000000000, "/usr/lib/xorg/Xorg"},
+	{1710, "/usr/bin/gnome-shell", 140 << 20, 310 << 20, 610 << 20, 6, 0, false, 1760000004000, "/usr/bin/gnome-shell"},
+	{2288, "/usr/lib/firefox/firefox", 210 << 20, 520 << 20, 1900 << 20, 12, 18, false, 1760000120000, "/usr/lib/firefox/firefox"},
+	{3051, "/opt/blender/blender scene.blend", 420 << 20, 1500 << 20, 2 << 30, 48, 0, false, 1760000600000, "/opt/blender/blender"},
+	{3377, "python3 train.py --epochs 40", 780 << 20, 4 << 30, 3 << 30, 95, 0, true, 1760000900000, "/usr/bin/python3.12"},
+	{3920, "/usr/bin/code", 0, 0, 1400 << 20, 4, 0, false, 1760000300000, "/usr/share/code/code"},
+}
+
+func newSimulator() *simulator { return &simulator{} }
+
+// wave varies base by up to ±amp, with the given period in samples
+func (s *simulator) wave(base uint64, amp float64, period float64, phase float64) uint64 {
+	return uint64(float64(base) * (1 + amp*math.Sin(2*math.Pi*float64(s.n)/period+phase)))
+}
+
+func (s *simulator) snapshot() (collect.Snapshot, error) {
+	s.n++
+	const gib = 1 << 30
+
+	snap := collect.Snapshot{
+		Timestamp:       time.Now(),
+		TotalRAM:        30 * gib,
+		SlabReclaimable: s.wave(900<<20, 0.05, 90, 0),
+		SlabUnreclaim:   310 << 20,
+		ScanTime:        time.Duration(s.wave(12, 0.3, 17, 0)) * time.Millisecond,
+	}
+
+	var vramUsed, gttUsed, ramUsed uint64
+	for i, sp := range simProcesses {
+		phase := float64(i)
+		p := collect.ProcessGPUInfo{
+			PID:        sp.pid,
+			Name:       sp.name,
+			VRAM:       s.wave(sp.vram, 0.08, 60+float64(i)*13, phase),
+			GTT:        s.wave(sp.gtt, 0.1, 45+float64(i)*7, phase),
+			RAM:        s.wave(sp.ram, 0.04, 120, phase),
+			UID:        1000,
+			CPU:        float64(s.wave(uint64(sp.cpu*10), 0.3, 11+float64(i), phase)) / 10,
+			Decode:     sp.decode,
+			CreateTime: sp.createTime,
+			ROCm:       sp.rocm,
+			Exe:        sp.exe,
+		}
+		p.RSS = p.RAM + p.GTT
+		p.OOMScore = int(p.RSS * 1000 / (30 * gib)) // how the kernel scales it, without oom_score_adj
+		if p.VRAM > 0 || p.GTT > 0 {
+			p.Card = "card1"
+			p.Handles = 1 + i%3
+			p.Nodes = []string{"/dev/dri/renderD128"}
+		}
+		vramUsed += p.VRAM
+		gttUsed += p.GTT
+		ramUsed += p.RSS
+		snap.Processes = append(snap.Processes, p)
+	}
+	// The firefox/gnome-shell compositor buffer is held by both
+	snap.Processes[1].Shared = 48 << 20
+	snap.Processes[2].Shared = 48 << 20
+
+	snap.UsedRAM = ramUsed + 10*gib // the rest of the system
+	snap.AvailableRAM = snap.TotalRAM - snap.UsedRAM
+	snap.FreeRAM = snap.AvailableRAM - 3*gib // the rest is page cache
+	snap.CommittedAS = snap.UsedRAM * 6 / 5
+	snap.CommitLimit = snap.TotalRAM/2 + 8*gib // overcommit_ratio 50, 8 GiB swap
+	snap.SwapTotal = 8 * gib
+	snap.SwapUsed = 1200<<20 + uint64(s.wave(200, 0.05, 100, 0))<<20
+	snap.Zram = []collect.ZramDevice{{
+		Name:       "zram0",
+		DiskSize:   snap.SwapTotal,
+		Original:   snap.SwapUsed,
+		Compressed: snap.SwapUsed * 2 / 7,
+		MemUsed:    snap.SwapUsed * 3 / 10,
+		Algorithm:  "zstd",
+	}}
+	snap.GPUs = []collect.GPUInfo{{
+		Card:           "card1",
+		PCI:            "0000:c4:00.0",
+		Model:          "Radeon 780M (simulated)",
+		VRAMTotal:      2 * gib,
+		VRAMUsed:       vramUsed + 150<<20, // plus kernel allocations
+		GTTTotal:       15 * gib,
+		GTTUsed:        gttUsed + 90<<20,
+		PerfLevel:      "auto",
+		PowerState:     "balanced",
+		HasMemBusy:     true,
+		MemBusyPercent: float64(s.wave(40, 0.5, 25, 0)),
+		HasBusy:        true,
+		BusyPercent:    float64(s.wave(55, 0.6, 30, 0)),
+		HasTemp:        true,
+		Temp:           float64(s.wave(58, 0.15, 90, 0)),
+		TempCrit:       100,
+	}}
+	return snap, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"mem-monitor/collect"
+)
+
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS samples (
+	ts   TEXT NOT NULL, -- RFC 3339, UTC
+	pid  INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	card TEXT NOT NULL,
+	vram INTEGER NOT NULL,
+	gtt  INTEGER NOT NULL,
+	ram  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS samples_ts ON samples (ts);
+`
+
+// sqliteSink appends every tick's process rows to a SQLite database. It
+// feeds SQL to the sqlite3 shell rather than linking a driver, so the
+// build stays dependency free; each tick is one transaction.
+type sqliteSink struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	w      *bufio.Writer
+	stderr bytes.Buffer
+}
+
+func newSQLiteSink(path string) (*sqliteSink, error) {
+	bin, err := exec.LookPath("sqlite3")
+	if err != nil {
+		return nil, fmt.Errorf("--sqlite needs the sqlite3 command: %w", err)
+	}
+	s := &sqliteSink{cmd: exec.Command(bin, "-batch", "-bail", path)}
+	s.cmd.Stderr = &s.stderr
+	if s.stdin, err = s.cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	if err := s.cmd.Start(); err != nil {
+		return nil, err
+	}
+	s.w = bufio.NewWriter(s.stdin)
+	if _, err := s.w.WriteString(sqliteSchema); err != nil {
+		return nil, err
+	}
+	return s, s.w.Flush()
+}
+
+func (s *sqliteSink) observe(at time.Time, procs []collect.ProcessGPUInfo) error {
+	ts := sqlQuote(at.UTC().Format(time.RFC3339Nano))
+	s.w.WriteString("BEGIN;\n")
+	for _, p := range procs {
+		fmt.Fprintf(s.w, "INSERT INTO samples VALUES (%s, %d, %s, %s, %d, %d, %d);\n",
+			ts, p.PID, sqlQuote(p.Name), sqlQuote(p.Card), p.VRAM, p.GTT, p.RAM)
+	}
+	s.w.WriteString("COMMIT;\n")
+	if err := s.w.Flush(); err != nil {
+		return s.failure(err)
+	}
+	return nil
+}
+
+// close ends the session and waits for sqlite3 to write everything out
+func (s *sqliteSink) close() error {
+	s.w.Flush()
+	s.stdin.Close()
+	if err := s.cmd.Wait(); err != nil {
+		return s.failure(err)
+	}
+	return nil
+}
+
+// failure prefers what sqlite3 said over a bare broken pipe
+func (s *sqliteSink) failure(err error) error {
+	if msg := strings.TrimSpace(s.stderr.String()); msg != "" {
+		return fmt.Errorf("sqlite3: %s", msg)
+	}
+	return fmt.Errorf("sqlite3: %w", err)
+}
+
+// sqlQuote renders s as a SQL string literal
+func sqlQuote(s string) string {
+	s = strings.ReplaceAll(s, "\x00", " ")
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// savedState is what we remember between runs
+type savedState struct {
+	Columns []string `json:"columns,omitempty"`
+	// Columns turned on or off with [o], by id; the rest keep their default
+	ShownColumns map[string]bool `json:"shown_columns,omitempty"`
+	Presets      []filterPreset  `json:"presets,omitempty"`
+}
+
+func statePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "mem-monitor", "state.json"), nil
+}
+
+// loadState returns the saved state, or the zero state if there is none
+func loadState() savedState {
+	var st savedState
+	path, err := statePath()
+	if err != nil {
+		return st
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return st
+	}
+	json.Unmarshal(data, &st)
+	return st
+}
+
+// saveState replaces the whole file; load it first to change one field
+func saveState(st savedState) error {
+	path, err := statePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"mem-monitor/collect"
+)
+
+// defaultStatusFormat is --statusline's line when --format isn't given
+const defaultStatusFormat = "RAM {ram}/{ram_total} VRAM {vram}/{vram_total}"
+
+// statusPlaceholder matches a {field} of a --format template
+var statusPlaceholder = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// statusFields renders each --format field from a snapshot and its
+// selected card, which is nil on machines without one
+var statusFields = map[string]func(s collect.Snapshot, g *collect.GPUInfo) string{
+	"ram":        func(s collect.Snapshot, g *collect.GPUInfo) string { return formatBytes(s.UsedRAM) },
+	"ram_total":  func(s collect.Snapshot, g *collect.GPUInfo) string { return formatBytes(s.TotalRAM) },
+	"ram_pct":    func(s collect.Snapshot, g *collect.GPUInfo) string { return formatPercent(s.UsedRAM, s.TotalRAM) },
+	"avail":      func(s collect.Snapshot, g *collect.GPUInfo) string { return formatBytes(s.AvailableRAM) },
+	"card":       gpuField(func(g *collect.GPUInfo) string { return g.Card }),
+	"vram":       gpuField(func(g *collect.GPUInfo) string { return formatBytes(g.VRAMUsed) }),
+	"vram_total": gpuField(func(g *collect.GPUInfo) string { return formatBytes(g.VRAMTotal) }),
+	"vram_pct":   gpuField(func(g *collect.GPUInfo) string { return formatPercent(g.VRAMUsed, g.VRAMTotal) }),
+	"gtt":        gpuField(func(g *collect.GPUInfo) string { return formatBytes(g.GTTUsed) }),
+	"gtt_total":  gpuField(func(g *collect.GPUInfo) string { return formatBytes(g.GTTTotal) }),
+	"gtt_pct":    gpuField(func(g *collect.GPUInfo) string { return formatPercent(g.GTTUsed, g.GTTTotal) }),
+}
+
+// gpuField is a card field that reads "n/a" without a card
+func gpuField(f func(g *collect.GPUInfo) string) func(collect.Snapshot, *collect.GPUInfo) string {
+	return func(_ collect.Snapshot, g *collect.GPUInfo) string {
+		if g == nil {
+			return "n/a"
+		}
+		return f(g)
+	}
+}
+
+func formatPercent(used, total uint64) string {
+	if total == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.0f%%", float64(used)/float64(total)*100)
+}
+
+// checkStatusFormat rejects templates naming fields that don't exist, so a
+// typo fails loudly once instead of printing garbage into a status bar
+func checkStatusFormat(format string) error {
+	for _, m := range statusPlaceholder.FindAllStringSubmatch(format, -1) {
+		if statusFields[m[1]] == nil {
+			names := slices.Sorted(maps.Keys(statusFields))
+			return fmt.Errorf("unknown --format field {%s}, expected one of %s", m[1], strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// runStatusLine prints one snapshot from scan as a single line of
+// format, for status bars that run it every few seconds. pci picks the
+// card, the first one when empty.
+func runStatusLine(out io.Writer, scan func() (collect.Snapshot, error), format, pci string) error {
+	snap, err := scan()
+	if err != nil {
+		return err
+	}
+	var g *collect.GPUInfo
+	if len(snap.GPUs) > 0 {
+		g = &snap.GPUs[0]
+		if i := collect.FindGPUByPCI(snap.GPUs, pci); pci != "" && i >= 0 {
+			g = &snap.GPUs[i]
+		}
+	}
+	line := statusPlaceholder.ReplaceAllStringFunc(format, func(field string) string {
+		return statusFields[field[1:len(field)-1]](snap, g)
+	})
+	_, err = fmt.Fprintln(out, line)
+	return err
+}
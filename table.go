@@ -0,0 +1,380 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"mem-monitor/collect"
+)
+
+var selectedColumnStyle = lipgloss.NewStyle().
+	Bold(true).
+	Underline(true)
+
+// column is one column of the process table. The table is built from the
+// ordered column list on model, which the user can rearrange, and show or
+// hide, with [o].
+type column struct {
+	id       string // stable name, used to persist the order
+	title    string
+	width    int
+	bytes    bool               // shows formatBytes values, narrower with --compact-numbers
+	sortBy   string             // sort key this column represents, if any
+	shown    func(m model) bool // nil means always shown
+	optional bool               // hidden until turned on, to fit the table in 120 cells
+	cell     func(m model, p collect.ProcessGPUInfo, t tableCtx) string
+}
+
+// tableCtx holds values computed over all displayed rows
+type tableCtx struct {
+	maxVRAM uint64
+}
+
+var allColumns = []column{
+	{id: "pid", title: "PID", width: 6, cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		return strconv.Itoa(int(p.PID))
+	}},
+	{id: "command", title: "COMMAND", width: 40, cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		name := p.Name
+		if m.shortNames {
+			name = commandBase(name)
+		}
+		if m.isNew(p) {
+			return newBadge + formatName(name, 40-len(newBadge))
+		}
+		return formatName(name, 40)
+	}},
+	{id: "vram", title: "VRAM", width: 19, bytes: true, sortBy: "VRAM", cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		return fmt.Sprintf("%-*s %s", bytesWidth(), m.deltaCell(p, func(p collect.ProcessGPUInfo) uint64 { return p.VRAM }), miniBar(p.VRAM, t.maxVRAM, 6))
+	}},
+	{id: "gtt", title: "GTT", width: 12, bytes: true, sortBy: "GTT", cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		return m.deltaCell(p, func(p collect.ProcessGPUInfo) uint64 { return p.GTT })
+	}},
+	{id: "ram", title: "RAM", width: 12, bytes: true, sortBy: "RAM", cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		return m.deltaCell(p, func(p collect.ProcessGPUInfo) uint64 { return p.RAM })
+	}},
+	{id: "swap", title: "SWAP", width: 12, bytes: true, sortBy: "SWAP", optional: true, cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		return formatBytes(p.Swap)
+	}},
+	{id: "growth", title: "GROWTH", width: 15, bytes: true, sortBy: "GROWTH", optional: true, cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		return formatRate(m.growthRate(p))
+	}},
+	{id: "cpu", title: "CPU%", width: 6, sortBy: "CPU", cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		return fmt.Sprintf("%.1f", p.CPU)
+	}},
+	{id: "dec", title: "DEC%", width: 5, optional: true, cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		return fmt.Sprintf("%.0f", p.Decode)
+	}},
+	{id: "enc", title: "ENC%", width: 5, optional: true, cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		return fmt.Sprintf("%.0f", p.Encode)
+	}},
+	{id: "rss", title: "RSS", width: 12, bytes: true, shown: func(m model) bool { return m.verbose }, cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		return formatBytes(p.RSS)
+	}},
+	{id: "shared", title: "SHARED", width: 12, bytes: true, optional: true, cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		return formatBytes(p.Shared)
+	}},
+	{id: "handles", title: "HANDLES", width: 7, optional: true, cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		return strconv.Itoa(p.Handles)
+	}},
+	{id: "rocm", title: "ROCM", width: 4, shown: func(m model) bool { return m.anyROCm() }, cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		if p.ROCm {
+			return "yes"
+		}
+		return ""
+	}},
+	// Processes in another PID namespace, usually containers, with the PID
+	// they see for themselves
+	{id: "nspid", title: "NS PID", width: 7, shown: func(m model) bool { return m.anyNamespaced() }, cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		if p.NSPID == 0 {
+			return ""
+		}
+		return strconv.Itoa(int(p.NSPID))
+	}},
+	// RSS against the cgroup limit is what predicts an OOM kill in a container
+	{id: "limit", title: "%LIMIT", width: 6, shown: func(m model) bool { return m.anyCgroupLimit() }, cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		if p.CgroupLimit == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%.1f", float64(p.RSS)/float64(p.CgroupLimit)*100)
+	}},
+	// Whom the kernel's OOM killer picks first
+	{id: "oom", title: "OOM", width: 5, sortBy: "OOM", optional: true, cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		return strconv.Itoa(p.OOMScore)
+	}},
+	{id: "oomadj", title: "OOMADJ", width: 6, shown: func(m model) bool { return m.anyOOMScoreAdj() }, cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		return strconv.Itoa(p.OOMScoreAdj)
+	}},
+	// The binary really running, to tell same-named installs apart
+	{id: "exe", title: "EXE", width: 30, shown: func(m model) bool { return m.showExe }, cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		if p.Exe == "" {
+			return "?"
+		}
+		return formatName(p.Exe, 30)
+	}},
+	// Which DRI node the process opened, e.g. to check what it's bound to
+	{id: "node", title: "NODE", width: 10, shown: func(m model) bool { return m.showNodes }, cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		names := make([]string, len(p.Nodes))
+		for i, n := range p.Nodes {
+			names[i] = filepath.Base(n)
+		}
+		return strings.Join(names, ",")
+	}},
+	// Only worth a column when there's more than one card to tell apart
+	{id: "card", title: "CARD", width: 8, shown: func(m model) bool { return len(m.gpus) > 1 }, cell: func(m model, p collect.ProcessGPUInfo, t tableCtx) string {
+		return p.Card
+	}},
+}
+
+// cellWidth is the column's width in the current number format
+func (c column) cellWidth() int {
+	if c.bytes {
+		return c.width - 12 + bytesWidth()
+	}
+	return c.width
+}
+
+func defaultColumnOrder() []string {
+	ids := make([]string, len(allColumns))
+	for i, c := range allColumns {
+		ids[i] = c.id
+	}
+	return ids
+}
+
+// normalizeColumnOrder drops unknown ids from a saved order and appends any
+// columns it is missing, e.g. ones added since it was saved
+func normalizeColumnOrder(ids []string) []string {
+	known := map[string]bool{}
+	for _, c := range allColumns {
+		known[c.id] = true
+	}
+
+	var order []string
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if known[id] && !seen[id] {
+			order = append(order, id)
+			seen[id] = true
+		}
+	}
+	for _, c := range allColumns {
+		if !seen[c.id] {
+			order = append(order, c.id)
+		}
+	}
+	return order
+}
+
+// anyROCm reports whether the last scan found a ROCm client, which is
+// what makes the ROCM column worth its space
+func (m model) anyROCm() bool {
+	for _, p := range m.scanned {
+		if p.ROCm {
+			return true
+		}
+	}
+	return false
+}
+
+// anyNamespaced reports whether a listed process is in another PID namespace
+func (m model) anyNamespaced() bool {
+	for _, p := range m.scanned {
+		if p.NSPID != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// anyOOMScoreAdj reports whether a listed process has its OOM-kill
+// priority adjusted, e.g. systemd services or Chrome tabs
+func (m model) anyOOMScoreAdj() bool {
+	for _, p := range m.scanned {
+		if p.OOMScoreAdj != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// anyCgroupLimit reports whether a listed process runs under a memory limit
+func (m model) anyCgroupLimit() bool {
+	for _, p := range m.scanned {
+		if p.CgroupLimit > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// columnOn reports whether the user has c turned on, or it is by default.
+// The sort column is always on, so sorting by a hidden one shows it.
+func (m model) columnOn(c column) bool {
+	sorted := c.sortBy != "" && c.sortBy == m.sortBy
+	if on, ok := m.columnToggles[c.id]; ok {
+		return on || sorted
+	}
+	return !c.optional || sorted
+}
+
+// visibleColumns returns the columns shown in the current mode, in order.
+// While reordering, columns turned off are included so they can be turned
+// back on.
+func (m model) visibleColumns() []column {
+	byID := map[string]column{}
+	for _, c := range allColumns {
+		byID[c.id] = c
+	}
+
+	var cols []column
+	for _, id := range m.columns {
+		c := byID[id]
+		if (c.shown == nil || c.shown(m)) && (m.reordering || m.columnOn(c)) {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+// toggleColumn turns the selected column off, or on again, while reordering
+func (m *model) toggleColumn() {
+	cols := m.visibleColumns()
+	if m.selectedColumn < 0 || m.selectedColumn >= len(cols) {
+		return
+	}
+	c := cols[m.selectedColumn]
+	if m.columnToggles == nil {
+		m.columnToggles = map[string]bool{}
+	}
+	m.columnToggles[c.id] = !m.columnOn(c)
+}
+
+// hiddenColumnTitles lists the columns turned off, for the [o] footer where
+// they are otherwise only told apart by being faint
+func (m model) hiddenColumnTitles() []string {
+	var titles []string
+	for _, c := range m.visibleColumns() {
+		if !m.columnOn(c) {
+			titles = append(titles, c.title)
+		}
+	}
+	return titles
+}
+
+// moveColumn swaps the selected visible column with its visible neighbour
+// in direction dir (-1 left, +1 right)
+func (m *model) moveColumn(dir int) {
+	cols := m.visibleColumns()
+	target := m.selectedColumn + dir
+	if m.selectedColumn < 0 || m.selectedColumn >= len(cols) || target < 0 || target >= len(cols) {
+		return
+	}
+
+	order := append([]string(nil), m.columns...)
+	a, b := indexOf(order, cols[m.selectedColumn].id), indexOf(order, cols[target].id)
+	order[a], order[b] = order[b], order[a]
+	m.columns = order
+	m.selectedColumn = target
+}
+
+func indexOf(vals []string, v string) int {
+	for i, s := range vals {
+		if s == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// deltaCell formats a value, or its change since the baseline when one is set
+func (m model) deltaCell(p collect.ProcessGPUInfo, val func(collect.ProcessGPUInfo) uint64) string {
+	if m.baseline == nil {
+		return formatBytes(val(p))
+	}
+	// Processes started after the baseline are compared against zero
+	base := m.baseline[procKey{p.PID, p.Card}]
+	return formatDelta(val(p), val(base))
+}
+
+func (m model) tableHeader(cols []column) string {
+	cells := make([]string, len(cols))
+	for i, c := range cols {
+		cell := fmt.Sprintf("%-*s", c.cellWidth(), c.title)
+		switch {
+		case m.reordering && i == m.selectedColumn:
+			cell = selectedColumnStyle.Render(cell)
+		case !m.columnOn(c):
+			cell = dimStyle.Render(cell)
+		case c.sortBy != "" && c.sortBy == m.sortBy:
+			cell = activeHeaderStyle.Render(cell)
+		}
+		cells[i] = cell
+	}
+	return strings.Join(cells, " ")
+}
+
+func (m model) tableRow(cols []column, p collect.ProcessGPUInfo, t tableCtx) string {
+	// Without styling, faint rows can't be drawn; blanking the GPU cells of
+	// idle rows is the closest plain-text equivalent
+	blankIdle := m.dimIdle && m.noColor && isGPUIdle(p)
+
+	cells := make([]string, len(cols))
+	for i, c := range cols {
+		cell := c.cell(m, p, t)
+		if blankIdle && (c.id == "vram" || c.id == "gtt") {
+			cell = "-"
+		}
+		// Padded by display width, names can hold double-width characters
+		cells[i] = cell + strings.Repeat(" ", max(0, c.cellWidth()-lipgloss.Width(cell)))
+	}
+	return strings.Join(cells, " ")
+}
+
+// commandBase is the program name of a cmdline, e.g. "python3" for
+// "/usr/bin/python3 train.py"
+func commandBase(cmdline string) string {
+	argv0, _, _ := strings.Cut(cmdline, " ")
+	return filepath.Base(argv0)
+}
+
+// newBadgeFor is how long a process that just showed up is marked NEW
+const newBadgeFor = 5 * time.Second
+
+const newBadge = "NEW "
+
+// trackArrivals notes the processes that weren't in the previous scan.
+// Nothing is new on the first one.
+func (m *model) trackArrivals(at time.Time) {
+	pids := make(map[int32]bool, len(m.scanned))
+	for _, p := range m.scanned {
+		pids[p.PID] = true
+		if m.prevPIDs != nil && !m.prevPIDs[p.PID] {
+			if m.arrived == nil {
+				m.arrived = map[int32]time.Time{}
+			}
+			m.arrived[p.PID] = at
+		}
+	}
+	for pid, since := range m.arrived {
+		if !pids[pid] || at.Sub(since) >= newBadgeFor {
+			delete(m.arrived, pid)
+		}
+	}
+	m.prevPIDs = pids
+}
+
+func (m model) isNew(p collect.ProcessGPUInfo) bool {
+	_, ok := m.arrived[p.PID]
+	return ok
+}
+
+// isGPUIdle reports rows that made the list on RAM alone
+func isGPUIdle(p collect.ProcessGPUInfo) bool {
+	return p.VRAM == 0 && p.GTT == 0
+}
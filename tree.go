@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// treeNode is one line of the physical memory breakdown
+type treeNode struct {
+	label    string
+	value    string
+	children []treeNode
+}
+
+// breakdownDepth is how many levels the breakdown has below its root; [t]
+// collapses one more of them per press, then expands everything again
+const breakdownDepth = 2
+
+// treeValueColumn is where values start, so they line up across levels
+const treeValueColumn = 21
+
+// render draws the tree down to depth levels below the root. Nodes whose
+// children are cut off are marked [+].
+func (n treeNode) render(depth int) string {
+	var s strings.Builder
+	n.renderLine(&s, "", "", depth)
+	return s.String()
+}
+
+func (n treeNode) renderLine(s *strings.Builder, branch, indent string, depth int) {
+	head := branch + n.label + ":"
+	// Box-drawing characters are one cell but three bytes
+	pad := max(1, treeValueColumn-len([]rune(head)))
+	line := head + strings.Repeat(" ", pad) + n.value
+	if depth == 0 && len(n.children) > 0 {
+		line += " [+]"
+	}
+	fmt.Fprintln(s, line)
+	if depth == 0 {
+		return
+	}
+
+	for i, c := range n.children {
+		if i == len(n.children)-1 {
+			c.renderLine(s, indent+"  └─ ", indent+"    ", depth-1)
+		} else {
+			c.renderLine(s, indent+"  ├─ ", indent+"  │ ", depth-1)
+		}
+	}
+}
+
+// swapNode is the breakdown's swap line, with each zram device's
+// compression below it
+func (m model) swapNode() treeNode {
+	n := treeNode{label: "Swap", value: "none"}
+	if m.swapTotal > 0 {
+		n.value = fmt.Sprintf("%s / %s (%.1f%%)", formatBytes(m.swapUsed), formatBytes(m.swapTotal),
+			float64(m.swapUsed)/float64(m.swapTotal)*100)
+	}
+	for _, z := range m.zram {
+		value := fmt.Sprintf("%s in %s of RAM", formatBytes(z.Original), formatBytes(z.MemUsed))
+		if z.MemUsed > 0 {
+			value += fmt.Sprintf(" (%.1fx", float64(z.Original)/float64(z.MemUsed))
+			if z.Algorithm != "" {
+				value += ", " + z.Algorithm
+			}
+			value += ")"
+		}
+		n.children = append(n.children, treeNode{label: z.Name, value: value})
+	}
+	return n
+}